@@ -0,0 +1,108 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// Combine merges the files generated for the same package, e.g. by several
+// Generators, into one. Imports are deduplicated, and the header of the first
+// output, with its build constraints, is kept.
+func Combine(outputs ...[]byte) ([]byte, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("no output to combine")
+	}
+
+	var (
+		header  []byte
+		pkgName string
+		bodies  bytes.Buffer
+	)
+
+	// paths holds the name each import path is imported with, and names
+	// the path each name refers to, to detect conflicting aliases.
+	paths := map[string]string{}
+	names := map[string]string{}
+
+	fset := token.NewFileSet()
+	for i, src := range outputs {
+		f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("parsing output %d: %v", i, err)
+		}
+
+		if i == 0 {
+			header = src[:fset.Position(f.Package).Offset]
+			pkgName = f.Name.Name
+		} else if f.Name.Name != pkgName {
+			return nil, fmt.Errorf("output %d is in package %q, not %q", i, f.Name.Name, pkgName)
+		}
+
+		bodyStart := fset.Position(f.Name.End()).Offset
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				bodyStart = fset.Position(gen.End()).Offset
+			}
+		}
+
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil, fmt.Errorf("output %d: %v", i, err)
+			}
+
+			var name string
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+
+			if prev, ok := paths[path]; ok && prev != name {
+				return nil, fmt.Errorf("output %d imports %q as %q, not %q", i, path, name, prev)
+			}
+			if name != "" {
+				if prev, ok := names[name]; ok && prev != path {
+					return nil, fmt.Errorf("output %d imports %q as %q, already used for %q", i, path, name, prev)
+				}
+				names[name] = path
+			}
+			paths[path] = name
+		}
+
+		bodies.Write(src[bodyStart:])
+		bodies.WriteString("\n\n")
+	}
+
+	var file bytes.Buffer
+	file.Write(header)
+	fmt.Fprintf(&file, "package %s\n\n", pkgName)
+
+	if len(paths) > 0 {
+		sorted := make([]string, 0, len(paths))
+		for path := range paths {
+			sorted = append(sorted, path)
+		}
+		sort.Strings(sorted)
+
+		file.WriteString("import (\n")
+		for _, path := range sorted {
+			fmt.Fprintf(&file, "%s %q\n", paths[path], path)
+		}
+		file.WriteString(")\n\n")
+	}
+
+	bodies.WriteTo(&file)
+
+	b, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting source: %w\nsource:\n%s", err, file.String())
+	}
+
+	return b, nil
+}
@@ -0,0 +1,80 @@
+package deepcopy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCombine(t *testing.T) {
+	t.Run("overlapping imports", func(t *testing.T) {
+		a := []byte(`// Code generated by deep-copy; DO NOT EDIT.
+
+package p
+
+import (
+	"time"
+)
+
+// DeepCopy generates a deep copy of A
+func (o A) DeepCopy() A {
+	var cp A = o
+	return cp
+}
+`)
+		b := []byte(`// Code generated by deep-copy; DO NOT EDIT.
+
+package p
+
+import (
+	"sync"
+	"time"
+)
+
+// DeepCopy generates a deep copy of B
+func (o B) DeepCopy() B {
+	var cp B = o
+	return cp
+}
+`)
+
+		got, err := Combine(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `// Code generated by deep-copy; DO NOT EDIT.
+
+package p
+
+import (
+	"sync"
+	"time"
+)
+
+// DeepCopy generates a deep copy of A
+func (o A) DeepCopy() A {
+	var cp A = o
+	return cp
+}
+
+// DeepCopy generates a deep copy of B
+func (o B) DeepCopy() B {
+	var cp B = o
+	return cp
+}
+`, string(got))
+	})
+
+	t.Run("conflicting aliases", func(t *testing.T) {
+		a := []byte("package p\n\nimport x \"example.com/a/x\"\n")
+		b := []byte("package p\n\nimport x \"example.com/b/x\"\n")
+
+		_, err := Combine(a, b)
+		assert.Error(t, err)
+	})
+
+	t.Run("different packages", func(t *testing.T) {
+		_, err := Combine([]byte("package p\n"), []byte("package q\n"))
+		assert.Error(t, err)
+	})
+}
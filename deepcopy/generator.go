@@ -4,18 +4,32 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/build/constraint"
 	"go/format"
+	"go/parser"
+	"go/token"
 	"go/types"
+	"go/version"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"unicode"
 
 	"golang.org/x/tools/go/packages"
 )
 
+// ErrMaxDepth is returned, wrapped, by the generation of functions copying
+// values shallowly below the max depth, with WithStrictMaxDepth.
+var ErrMaxDepth = errors.New("reached max depth")
+
 type SkipLists []map[string]struct{}
 
 func (l SkipLists) Get(i int) (s skips) {
@@ -29,14 +43,119 @@ func (l SkipLists) Get(i int) (s skips) {
 type Generator struct {
 	isPtrRecv  bool
 	maxDepth   int
+	depthNotes bool
+	strictMax  bool
 	methodName string
+	methodTmpl *template.Template
+	tmplErr    error
+	docTmpl    *template.Template
+	docErr     error
+	methods    map[string]string
+	results    map[string]string
 	skipLists  SkipLists
+	keyLists   SkipLists
+	allKeys    bool
+	resetLists SkipLists
+	cowLists   SkipLists
+	inclLists  SkipLists
+	oneOfLists SkipLists
 	buildTags  []string
-
-	imports map[string]string
-	fns     [][]byte
+	nolint     []string
+	constraint string
+	pkgName    string
+	logger     *log.Logger
+	tagSkips   []tagSkip
+	packageDoc string
+	ifaces     InterfacePolicy
+	ifaceFuncs bool
+	importAs   map[string]string
+	chans      ChannelPolicy
+	errs       ErrorPolicy
+	chanBufs   bool
+	forwardRef bool
+	transitive bool
+	sourceRefs bool
+	manifest   bool
+	sharedPtrs map[string]struct{}
+	valueTypes map[string]struct{}
+	allocators map[string]string
+	nilPtrs    bool
+	verbose    bool
+	assertions bool
+	excluded   map[string]struct{}
+	allocCount string
+	ifaceLit   bool
+	nilSafe    bool
+	copierName string
+	explicit   bool
+	helperAt   int
+	standalone bool
+	copyInto   bool
+	reuseMaps  bool
+	fallible   bool
+	ptrVariant bool
+
+	imports *importSet
+	fns     []decl
+	helpers map[string]decl
+	scope   *types.Scope
+	// errReturn is the statement returning an error from the generated
+	// method in fallible mode.
+	errReturn string
+	// truncated are the values copied shallowly below the max depth, by
+	// all the functions generated at once.
+	truncated *[]string
+	// cycle is the recursive type, and the types it is mutually recursive
+	// with, whose pointers are copied once per value, through the visited
+	// map of its copy.
+	cycle []object
+	// reusedMaps are the maps of the destination of the copy which are
+	// cleared and refilled, if set. It is unset below the values the copy
+	// allocates.
+	reusedMaps *[]string
 }
 
+// InterfacePolicy controls how interface values are copied.
+type InterfacePolicy int
+
+const (
+	// ShareInterfaces shares interface values with the source.
+	ShareInterfaces InterfacePolicy = iota
+	// SwitchInterfaces copies interface values holding one of the generated
+	// types, or a pointer to one, with its generated method through a type
+	// switch. Other values are shared with the source.
+	SwitchInterfaces
+)
+
+// ErrorPolicy controls how values of the error interface are copied.
+type ErrorPolicy int
+
+const (
+	// ShareErrors copies errors like other interface values, sharing them
+	// with the source.
+	ShareErrors ErrorPolicy = iota
+	// WrapErrors wraps the error of the source in a new one with
+	// fmt.Errorf("%w"), so errors.Is and errors.As still match it.
+	WrapErrors
+	// SkipErrors leaves errors out of the copy, as nil.
+	SkipErrors
+)
+
+// ChannelPolicy controls how channels are copied. No policy copies the
+// elements buffered in the channel.
+type ChannelPolicy int
+
+const (
+	// RecreateChannels makes a new, empty channel with the capacity of the
+	// source.
+	RecreateChannels ChannelPolicy = iota
+	// ShareSignalChannels shares channels of struct{}, typically used as
+	// done or close signals, with the source. Other channels are recreated.
+	ShareSignalChannels
+	// ShareChannels shares all channels with the source.
+	ShareChannels
+)
+
 // GeneratorOption is a function to specify option for NewGenerator.
 type GeneratorOption func(*Generator)
 
@@ -54,6 +173,56 @@ func WithMethodName(n string) GeneratorOption {
 	}
 }
 
+// WithMethodNameTemplate is an option to name the method of each type of the
+// package by a text/template expanded with the type name as .Type, e.g.
+// "Copy{{.Type}}", instead of with the method name. In standalone mode, the
+// expanded name is the name of the function.
+func WithMethodNameTemplate(tmpl string) GeneratorOption {
+	return func(g *Generator) {
+		if tmpl == "" {
+			g.methodTmpl, g.tmplErr = nil, nil
+			return
+		}
+		g.methodTmpl, g.tmplErr = template.New("method").Parse(tmpl)
+	}
+}
+
+// WithMethodComment is an option to write the doc comment of each generated
+// method, or function, by a text/template expanded with its name as .Method,
+// the copied type as .Type, and the returned type as .Result, e.g.
+// "{{.Method}} returns a deep copy of {{.Type}}.", instead of the default
+// comment. Each line of the expansion is commented out.
+func WithMethodComment(tmpl string) GeneratorOption {
+	return func(g *Generator) {
+		if tmpl == "" {
+			g.docTmpl, g.docErr = nil, nil
+			return
+		}
+		g.docTmpl, g.docErr = template.New("comment").Parse(tmpl)
+	}
+}
+
+// WithMethodNames is an option to name the method of the types of the
+// package given by name differently, e.g. {"Foo": "Clone"}, instead of with
+// the method name. The reuse of the methods of these types looks for the
+// given names too.
+func WithMethodNames(names map[string]string) GeneratorOption {
+	return func(g *Generator) {
+		g.methods = names
+	}
+}
+
+// WithResultTypes is an option to return the copy of the types of the
+// package given by name as another type, e.g. {"Foo": "FooCopy"}, instead of
+// as the type itself. The result type must have the same underlying type, so
+// the copy converts to it. Values of these types nested in other types are
+// copied inline, as their method does not return a copy of them.
+func WithResultTypes(names map[string]string) GeneratorOption {
+	return func(g *Generator) {
+		g.results = names
+	}
+}
+
 // WithMaxDepth is an option to specify maxDepth.
 func WithMaxDepth(d int) GeneratorOption {
 	return func(g *Generator) {
@@ -61,6 +230,22 @@ func WithMaxDepth(d int) GeneratorOption {
 	}
 }
 
+// WithShallowOnMaxDepth is an option to mark each value copied shallowly,
+// as it is nested below the max depth, with a comment in the generated code.
+func WithShallowOnMaxDepth(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.depthNotes = f
+	}
+}
+
+// WithStrictMaxDepth is an option to fail with ErrMaxDepth, instead of only
+// warning, when values are copied shallowly below the max depth.
+func WithStrictMaxDepth(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.strictMax = f
+	}
+}
+
 // WithSkipLists is an option to specify skipLists
 func WithSkipLists(sl SkipLists) GeneratorOption {
 	return func(g *Generator) {
@@ -68,6 +253,78 @@ func WithSkipLists(sl SkipLists) GeneratorOption {
 	}
 }
 
+// WithIncludeLists is an option to specify the selectors which are deeply
+// copied, leaving the other fields of the type shallowly copied. The fields
+// leading to a selector are walked, e.g. B for B.I. Skips apply within the
+// included selectors.
+func WithIncludeLists(il SkipLists) GeneratorOption {
+	return func(g *Generator) {
+		g.inclLists = il
+	}
+}
+
+// WithKeyCopyLists is an option to specify map selectors whose keys are
+// deeply copied. Map keys are assigned as-is by default, since copying a key
+// that holds pointers changes its identity within the map.
+func WithKeyCopyLists(kl SkipLists) GeneratorOption {
+	return func(g *Generator) {
+		g.keyLists = kl
+	}
+}
+
+// WithDeepCopyMapKeys is an option to deeply copy the keys of all maps but
+// pointers, as if each of them was given with WithKeyCopyLists.
+func WithDeepCopyMapKeys(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.allKeys = f
+	}
+}
+
+// WithTagSkip is an option to skip deeply copying fields whose struct tag
+// has the given key, with a value accepted by match. Multiple options can be
+// specified.
+func WithTagSkip(key string, match func(value string) bool) GeneratorOption {
+	return func(g *Generator) {
+		g.tagSkips = append(g.tagSkips, tagSkip{key: key, match: match})
+	}
+}
+
+// WithResetLists is an option to specify field selectors which are set to
+// their zero value in the copy, regardless of the source.
+func WithResetLists(rl SkipLists) GeneratorOption {
+	return func(g *Generator) {
+		g.resetLists = rl
+	}
+}
+
+// WithCopyOnWriteLists is an option to specify field selectors which are
+// shared with the source, marked as copy-on-write: the caller is expected to
+// copy them before mutating either value.
+func WithCopyOnWriteLists(cl SkipLists) GeneratorOption {
+	return func(g *Generator) {
+		g.cowLists = cl
+	}
+}
+
+// WithOneOfLists is an option to specify, per type, the fields of a union
+// of which exactly one must be set. The generated method panics when the
+// source has none or several of them set.
+func WithOneOfLists(ol SkipLists) GeneratorOption {
+	return func(g *Generator) {
+		g.oneOfLists = ol
+	}
+}
+
+// WithImportAliases is an option to name the packages imported by the
+// generated file by the given aliases, by import path, e.g.
+// {"example.com/v2/models": "models2"}, instead of by their package name. An
+// alias already in use falls back to a name derived from the path.
+func WithImportAliases(aliases map[string]string) GeneratorOption {
+	return func(g *Generator) {
+		g.importAs = aliases
+	}
+}
+
 // WithBuildTags is an option to specify buildTags
 func WithBuildTags(bts []string) GeneratorOption {
 	return func(g *Generator) {
@@ -75,19 +332,341 @@ func WithBuildTags(bts []string) GeneratorOption {
 	}
 }
 
+// WithNolintDirectives is an option to exempt the generated functions from
+// the given linters, e.g. "gocyclo" and "funlen", with a //nolint directive.
+func WithNolintDirectives(linters ...string) GeneratorOption {
+	return func(g *Generator) {
+		g.nolint = linters
+	}
+}
+
+// WithBuildConstraint is an option to write the given build constraint,
+// e.g. "!ignore_autogenerated", above the header of the generated file. It
+// is joined with the build tags into a single constraint.
+func WithBuildConstraint(expr string) GeneratorOption {
+	return func(g *Generator) {
+		g.constraint = expr
+	}
+}
+
+// WithPackageName is an option to generate the file in the package with
+// the given name instead of the package of the types, e.g. a separate
+// foocopy package. The types are then qualified with their package, which
+// requires standalone functions.
+func WithPackageName(name string) GeneratorOption {
+	return func(g *Generator) {
+		g.pkgName = name
+	}
+}
+
+// WithPackageDoc is an option to specify a package doc comment, written
+// only when none of the package's files already has one.
+func WithPackageDoc(doc string) GeneratorOption {
+	return func(g *Generator) {
+		g.packageDoc = doc
+	}
+}
+
+// WithInterfacePolicy is an option to specify how interface values are
+// copied.
+func WithInterfacePolicy(p InterfacePolicy) GeneratorOption {
+	return func(g *Generator) {
+		g.ifaces = p
+	}
+}
+
+// WithInterfaceHelpers is an option to copy the values of each interface
+// type of the package declaring the method through a function generated
+// once for it, e.g. deepCopyShape(v Shape) Shape, instead of inline.
+func WithInterfaceHelpers(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.ifaceFuncs = f
+	}
+}
+
+// WithErrorPolicy is an option to specify how values of the error interface
+// are copied.
+func WithErrorPolicy(p ErrorPolicy) GeneratorOption {
+	return func(g *Generator) {
+		g.errs = p
+	}
+}
+
+// WithChannelPolicy is an option to specify how channels are copied.
+// Channels of single fields can be shared with the source by skipping them.
+func WithChannelPolicy(p ChannelPolicy) GeneratorOption {
+	return func(g *Generator) {
+		g.chans = p
+	}
+}
+
+// WithChannelBuffers is an option to copy the elements buffered in the
+// channels recreated by the copy into the new channels, shallowly. The
+// elements are received from the source and sent back to it, which is only
+// safe when no other goroutine uses the channel during the copy. Elements
+// of directional channels are not copied.
+func WithChannelBuffers(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.chanBufs = f
+	}
+}
+
+// WithForwardReferences is an option to copy values of struct types of the
+// generated package that have no method through the method anyway, instead of
+// inlining their copy, assuming the method is generated separately.
+func WithForwardReferences(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.forwardRef = f
+	}
+}
+
+// WithTransitiveTypes is an option to also generate the method of every
+// struct type of the generated package, without one, that is reachable from
+// the given types, and to copy their values through it.
+func WithTransitiveTypes(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.transitive = f
+	}
+}
+
+// WithSourceComments is an option to reference the file and line defining
+// the type in the comment of each generated method.
+func WithSourceComments(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.sourceRefs = f
+	}
+}
+
+// WithFieldManifest is an option to list the fields of each struct type,
+// with their types, in the comment of its generated method, so that changes
+// of the type show in the diff of the generated file.
+func WithFieldManifest(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.manifest = f
+	}
+}
+
+// WithSharedPointers is an option to share pointers to the given types,
+// qualified by their package path, e.g. "time.Location", instead of copying
+// the values they point to. Pointers to time.Location are always shared.
+func WithSharedPointers(names ...string) GeneratorOption {
+	return func(g *Generator) {
+		for _, name := range names {
+			g.sharedPtrs[name] = struct{}{}
+		}
+	}
+}
+
+// WithValueTypes is an option to copy values of the given types, qualified
+// by their package path, e.g. "net/netip.Addr", by assignment, without
+// descending into them. time.Time and time.Duration are always copied so.
+// Types holding references, e.g. math/big.Int, must not be given, as the
+// copy would share them.
+func WithValueTypes(names ...string) GeneratorOption {
+	return func(g *Generator) {
+		for _, name := range names {
+			g.valueTypes[name] = struct{}{}
+		}
+	}
+}
+
+// WithAllocators is an option to allocate the values pointed to by pointers
+// to the given types, qualified by their package path, e.g.
+// "example.com/pkg.Foo", with the given expressions of the pointer type, e.g.
+// "pool.GetFoo()", instead of with new. The value is then overwritten by the
+// copy.
+func WithAllocators(allocs map[string]string) GeneratorOption {
+	return func(g *Generator) {
+		g.allocators = allocs
+	}
+}
+
+// WithMaterializedNilPointers is an option to point nil pointers of the
+// source to a newly allocated zero value in the copy, instead of keeping them
+// nil.
+func WithMaterializedNilPointers(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.nilPtrs = f
+	}
+}
+
+// WithExcludedTypes is an option to leave the named types of the package out
+// of GenerateAll.
+func WithExcludedTypes(names ...string) GeneratorOption {
+	return func(g *Generator) {
+		g.excluded = make(map[string]struct{}, len(names))
+		for _, name := range names {
+			g.excluded[name] = struct{}{}
+		}
+	}
+}
+
+// WithAllocCounter is an option to declare a package-level atomic.Int64 with
+// the given name in the generated file, incremented on every allocation made
+// by the generated methods. It is meant for profiling expensive copies.
+func WithAllocCounter(name string) GeneratorOption {
+	return func(g *Generator) {
+		g.allocCount = name
+	}
+}
+
+// WithInterfaceLiteral is an option to render the empty interface as
+// interface{} in the generated code, for toolchains before Go 1.18, instead
+// of any.
+func WithInterfaceLiteral(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.ifaceLit = f
+	}
+}
+
+// WithNilSafety is an option to return nil when the method is called on a
+// nil pointer receiver, instead of panicking. It has no effect on value
+// receivers.
+func WithNilSafety(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.nilSafe = f
+	}
+}
+
+// WithCopierInterface is an option to declare a generic interface with the
+// given name, implemented by the types with the generated method, along with
+// a compile-time assertion per type. It lets consumers substitute copies in
+// tests.
+func WithCopierInterface(name string) GeneratorOption {
+	return func(g *Generator) {
+		g.copierName = name
+	}
+}
+
+// WithExplicitFields is an option to start the copy of a struct from its zero
+// value, and to assign each of its fields explicitly, instead of copying the
+// whole struct value before replacing its references.
+func WithExplicitFields(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.explicit = f
+	}
+}
+
+// WithHelperDepth is an option to copy struct types of the generated package
+// that are nested at least d levels deep with a helper function per type,
+// instead of inlining their copy. It bounds the nesting of the generated
+// code, and avoids duplicating it.
+func WithHelperDepth(d int) GeneratorOption {
+	return func(g *Generator) {
+		g.helperAt = d
+	}
+}
+
+// WithStandalone is an option to generate a function per type, named after
+// the method and the type, e.g. DeepCopyFoo(o Foo) Foo, instead of a method.
+func WithStandalone(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.standalone = f
+	}
+}
+
+// WithCopyInto is an option to generate a method copying into a destination
+// given by the caller, e.g. DeepCopyInto(dst *Foo), which the generated
+// method wraps. Callers can reuse the destination across copies.
+func WithCopyInto(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.copyInto = f
+	}
+}
+
+// WithMapReuse is an option to clear and refill the maps held by the
+// destination of DeepCopyInto, rather than allocating new ones, if the
+// module of the types requires Go 1.21, which declares clear. The
+// destination must not share its maps with the source.
+func WithMapReuse(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.reuseMaps = f
+	}
+}
+
+// WithFallible is an option to generate methods returning an error along
+// with the copy, e.g. DeepCopy() (Foo, error). Values of types with a
+// fallible method, e.g. DeepCopyE() (Foo, error), are copied with it, and
+// its error is returned.
+func WithFallible(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.fallible = f
+	}
+}
+
+// WithPointerVariant is an option to also generate a variant of each value
+// receiver method with a pointer receiver, returning a pointer, e.g.
+// DeepCopyPtr() *Foo, which calls the generated method.
+func WithPointerVariant(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.ptrVariant = f
+	}
+}
+
+// WithCopyAssertions is an option to also write a function per type into the
+// round-trip tests, e.g. assertFooDeepCopied(t, a, b), asserting that b is a
+// deep copy of a: equal to it, and sharing none of its slices, maps and
+// pointers, but for the skipped and copy-on-write selectors. The round-trip
+// tests call it, and so can other tests, with values of their own.
+func WithCopyAssertions(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.assertions = f
+	}
+}
+
+// WithVerbose is an option to log the walk of each copied type to the logger,
+// as a tree of the selectors and the actions taken on them, e.g. recurse,
+// skip or reuse, to debug e.g. selectors which don't match.
+func WithVerbose(f bool) GeneratorOption {
+	return func(g *Generator) {
+		g.verbose = f
+	}
+}
+
+// WithLogger is an option to log warnings about the generated code, e.g.
+// values copied shallowly, to l. Warnings are discarded by default.
+func WithLogger(l *log.Logger) GeneratorOption {
+	return func(g *Generator) {
+		g.logger = l
+	}
+}
+
 // NewGenerator generates a Generator with options.
 func NewGenerator(opts ...GeneratorOption) Generator {
 	g := Generator{
 		methodName: "DeepCopy",
-		imports:    map[string]string{},
-		fns:        [][]byte{},
+		sharedPtrs: map[string]struct{}{
+			// Locations are immutable, and compared by pointer.
+			"time.Location": {},
+		},
+		valueTypes: map[string]struct{}{
+			// Times hold no references but their location, which is shared.
+			"time.Time":     {},
+			"time.Duration": {},
+		},
+		fns: []decl{},
 	}
 	for _, opt := range opts {
 		opt(&g)
 	}
+	g.imports = g.newImports()
 	return g
 }
 
+// newImports returns an empty import set for a file, naming packages by the
+// import aliases.
+func (g Generator) newImports() *importSet {
+	s := newImportSet()
+	s.preferred = g.importAs
+	return s
+}
+
+// decl is a generated declaration, with the packages it uses.
+type decl struct {
+	code    []byte
+	imports *importSet
+}
+
 type object interface {
 	types.Type
 	Obj() *types.TypeName
@@ -105,6 +684,23 @@ type methoder interface {
 
 type skips map[string]struct{}
 
+// selectors are the selector sets applying to one generated type.
+type selectors struct {
+	skips  skips
+	keys   skips
+	resets skips
+	cows   skips
+	oneOf  skips
+	// includes, if any, are the only selectors deeply copied, along with
+	// the fields leading to them.
+	includes skips
+}
+
+type tagSkip struct {
+	key   string
+	match func(value string) bool
+}
+
 func (s skips) Contains(sel string) bool {
 	if _, ok := s[sel]; ok {
 		return ok
@@ -113,318 +709,2641 @@ func (s skips) Contains(sel string) bool {
 	return false
 }
 
-func (g Generator) Generate(w io.Writer, types []string, p *packages.Package) error {
-	objs := make([]object, len(types))
-	for i, kind := range types {
-		obj, err := locateType(kind, p)
-		if err != nil {
-			return fmt.Errorf("locating type %q in %q: %v", kind, p.Name, err)
-		}
-
-		objs[i] = obj
+// ContainsPath reports whether the canonical selector of the path is
+// contained. The selector is built in a stack buffer, so matching does not
+// allocate.
+func (s skips) ContainsPath(p path) bool {
+	if len(s) == 0 {
+		return false
 	}
 
-	for i, obj := range objs {
-		fn, err := g.generateFunc(p, obj, g.skipLists.Get(i), objs)
-		if err != nil {
-			return fmt.Errorf("generating method: %v", err)
+	var buf [128]byte
+	_, ok := s[string(p.appendCanonical(buf[:0]))]
+
+	return ok
+}
+
+// path is the selector of a value relative to the copied value, or to the
+// map key or value being copied, as field name, [i] and [k] segments. The
+// walk appends to it depth-first, so siblings reuse the same backing array.
+type path []string
+
+func (p path) appendTo(b []byte) []byte {
+	for i, seg := range p {
+		if i > 0 && !strings.HasPrefix(seg, "[") {
+			b = append(b, '.')
 		}
+		b = append(b, seg...)
+	}
 
-		g.fns = append(g.fns, fn)
+	return b
+}
+
+// appendCanonical appends the selector of the path with every slice, array
+// or map element segment as [], e.g. Items[].Secret, the form user selectors
+// are matched in.
+func (p path) appendCanonical(b []byte) []byte {
+	for i, seg := range p {
+		if strings.HasPrefix(seg, "[") {
+			b = append(b, "[]"...)
+			continue
+		}
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = append(b, seg...)
 	}
 
-	err := g.generateFile(w, p)
+	return b
+}
+
+// canonicalSelector returns sel with its element segments, e.g. [i], [k] or
+// [], as [].
+func canonicalSelector(sel string) string {
+	return string(splitSelector(sel).appendCanonical(nil))
+}
+
+func (p path) String() string {
+	return string(p.appendTo(nil))
+}
+
+// builtins are the predeclared functions and values the generated code uses.
+var builtins = []string{"cap", "copy", "len", "make", "new", "nil"}
+
+// Generate writes a file with the methods of the named types of p to w. The
+// package must be loaded with LoadMode, e.g. by LoadPackage.
+func (g Generator) Generate(w io.Writer, types []string, p *packages.Package) error {
+	objs, err := locateTypes(types, p)
 	if err != nil {
-		return fmt.Errorf("generating file content: %v", err)
+		return err
 	}
 
-	return nil
+	return g.generate(w, objs, p)
 }
 
-func (g Generator) generateFunc(p *packages.Package, obj object, skips skips, generating []object) ([]byte, error) {
-	var buf bytes.Buffer
-
-	var ptr string
-	if g.isPtrRecv {
-		ptr = "*"
+// GenerateEach is like Generate, but writes the methods of each type to its
+// own file, opened by open with the name of the type. Each file imports the
+// packages used by its methods only.
+func (g Generator) GenerateEach(open func(kind string) (io.WriteCloser, error), types []string, p *packages.Package) error {
+	objs, err := locateTypes(types, p)
+	if err != nil {
+		return err
 	}
-	kind := obj.Obj().Name()
 
-	source := "o"
-	fmt.Fprintf(&buf, `// %s generates a deep copy of %s%s
-func (o %s%s) %s() %s%s {
-	var cp %s = %s%s
-`, g.methodName, ptr, kind, ptr, kind, g.methodName, ptr, kind, kind, ptr, source)
+	return g.generateEach(open, objs, p)
+}
 
-	g.walkType(source, "cp", p.Name, obj, &buf, skips, generating, 0)
+// GenerateToFile is like Generate, but writes the file to path, replacing
+// it. An empty path names the file after the package, next to its sources,
+// e.g. foo_deepcopy.go in the directory of package foo. Nothing is written if
+// the generation fails.
+func (g Generator) GenerateToFile(path string, types []string, p *packages.Package) error {
+	if path == "" {
+		if len(p.GoFiles) == 0 {
+			return fmt.Errorf("no source files in %q to write the file next to", p.Name)
+		}
+		path = filepath.Join(filepath.Dir(p.GoFiles[0]), p.Name+"_deepcopy.go")
+	}
 
-	if g.isPtrRecv {
-		buf.WriteString("return &cp\n}")
-	} else {
-		buf.WriteString("return cp\n}")
+	var buf bytes.Buffer
+	if err := g.Generate(&buf, types, p); err != nil {
+		return err
 	}
 
-	return buf.Bytes(), nil
+	return os.WriteFile(path, buf.Bytes(), 0o666)
 }
 
-func (g Generator) generateFile(w io.Writer, p *packages.Package) error {
-	var file bytes.Buffer
+func locateTypes(types []string, p *packages.Package) ([]object, error) {
+	objs := make([]object, len(types))
+	for i, kind := range types {
+		obj, err := locateType(kind, p)
+		if err != nil {
+			return nil, fmt.Errorf("locating type %q in %q: %v", kind, p.Name, err)
+		}
+
+		objs[i] = obj
+	}
+
+	return objs, nil
+}
+
+// GenerateAll is like Generate, for all the types returned by AllTypes.
+func (g Generator) GenerateAll(w io.Writer, p *packages.Package) error {
+	names := g.AllTypes(p)
+	if len(names) == 0 {
+		return fmt.Errorf("no type to generate in %q", p.Name)
+	}
+
+	return g.Generate(w, names, p)
+}
+
+// AllTypes returns the names of the exported struct, slice and map types of
+// p, sorted, but aliases, the types excluded with WithExcludedTypes, and the
+// types with a hand-written method of the generated name.
+func (g Generator) AllTypes(p *packages.Package) []string {
+	generated := generatedFiles(p)
+
+	var names []string
+	scope := p.Types.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() || tn.IsAlias() {
+			continue
+		}
+		if _, ok := g.excluded[name]; ok {
+			continue
+		}
+
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		switch named.Underlying().(type) {
+		case *types.Struct, *types.Slice, *types.Map:
+		default:
+			continue
+		}
+
+		if g.hasHandWrittenMethod(named, p, generated) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// hasHandWrittenMethod reports whether named has a method of the generated
+// name, declared outside of the generated files of p.
+func (g Generator) hasHandWrittenMethod(named *types.Named, p *packages.Package, generated map[string]bool) bool {
+	for i := 0; i < named.NumMethods(); i++ {
+		m := named.Method(i)
+		if m.Name() != g.methodFor(named) {
+			continue
+		}
+
+		return p.Fset == nil || !generated[p.Fset.Position(m.Pos()).Filename]
+	}
+
+	return false
+}
+
+// GenerateForObjects is like Generate, for types already resolved, e.g. by an
+// analyzer, instead of their names. The types must be declared in p.
+func (g Generator) GenerateForObjects(w io.Writer, names []*types.TypeName, p *packages.Package) error {
+	objs := make([]object, len(names))
+	for i, name := range names {
+		obj, ok := name.Type().(object)
+		if !ok {
+			return fmt.Errorf("type %q is not a named type", name.Name())
+		}
+
+		if name.Pkg() == nil || name.Pkg().Path() != p.PkgPath {
+			return fmt.Errorf("type %q is not declared in %q", name.Name(), p.Name)
+		}
+
+		objs[i] = obj
+	}
+
+	return g.generate(w, objs, p)
+}
+
+func (g Generator) generate(w io.Writer, objs []object, p *packages.Package) error {
+	g.helpers = map[string]decl{}
+	g.scope = g.packageScope(p)
+	g.truncated = new([]string)
+
+	objs, err := g.prepare(objs, p)
+	if err != nil {
+		return err
+	}
+
+	g.fns = append(g.fns, g.declarations(objs)...)
+
+	for i, obj := range objs {
+		fns, err := g.generateType(p, i, obj, objs)
+		if err != nil {
+			return err
+		}
+
+		g.fns = append(g.fns, fns...)
+	}
+
+	g.fns = append(g.fns, g.helperFuncs(map[string]bool{})...)
+
+	if err := g.checkTruncated(); err != nil {
+		return err
+	}
+
+	err = g.generateFile(w, p)
+	if err != nil {
+		return fmt.Errorf("generating file content: %v", err)
+	}
+
+	return nil
+}
+
+// generateEach is like generate, but writes the methods of each object to
+// the file opened for it, with the imports of these methods only. Package
+// level declarations and the package doc are written to the first file, and
+// each helper function to the file of the first method calling it.
+func (g Generator) generateEach(open func(kind string) (io.WriteCloser, error), objs []object, p *packages.Package) error {
+	g.helpers = map[string]decl{}
+	g.scope = g.packageScope(p)
+	g.truncated = new([]string)
+
+	objs, err := g.prepare(objs, p)
+	if err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	files := make([]Generator, len(objs))
+	for i, obj := range objs {
+		f := g
+		f.imports, f.fns = g.newImports(), nil
+		if i == 0 {
+			f.fns = f.declarations(objs)
+		} else {
+			f.packageDoc = ""
+		}
+
+		fns, err := f.generateType(p, i, obj, objs)
+		if err != nil {
+			return err
+		}
+		f.fns = append(f.fns, fns...)
+		f.fns = append(f.fns, f.helperFuncs(written)...)
+		files[i] = f
+	}
+
+	// No file is written if any is incomplete.
+	if err := g.checkTruncated(); err != nil {
+		return err
+	}
+
+	for i, obj := range objs {
+		f := files[i]
+		w, err := open(obj.Obj().Name())
+		if err != nil {
+			return fmt.Errorf("opening the file of %s: %v", obj.Obj().Name(), err)
+		}
+
+		err = f.generateFile(w, p)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("generating file content: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// checkTruncated returns ErrMaxDepth, along with the values copied shallowly
+// below the max depth, if any, in strict mode.
+func (g Generator) checkTruncated() error {
+	if !g.strictMax || len(*g.truncated) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w %d, copying shallowly: %s", ErrMaxDepth, g.maxDepth, strings.Join(*g.truncated, ", "))
+}
+
+// packageScope returns the scope of p, if the file is generated in p.
+func (g Generator) packageScope(p *packages.Package) *types.Scope {
+	if p.Types != nil && g.outputName(p) == p.Name {
+		return p.Types.Scope()
+	}
+
+	return nil
+}
+
+// prepare checks that the methods of objs can be generated with the options
+// of g, and returns the objects to generate the methods of.
+func (g Generator) prepare(objs []object, p *packages.Package) ([]object, error) {
+	if g.outputName(p) != p.Name && !g.standalone {
+		return nil, fmt.Errorf("methods of the types in %q can not be declared in package %q; generate standalone functions instead", p.Name, g.outputName(p))
+	}
+
+	if g.scope != nil {
+		for _, name := range builtins {
+			if g.scope.Lookup(name) != nil {
+				return nil, fmt.Errorf("%q in %q shadows a builtin used by the generated code", name, p.Name)
+			}
+		}
+	}
+
+	if g.tmplErr != nil {
+		return nil, fmt.Errorf("parsing the method name template: %w", g.tmplErr)
+	}
+	if g.docErr != nil {
+		return nil, fmt.Errorf("parsing the method comment template: %w", g.docErr)
+	}
+	if g.docTmpl != nil {
+		// The fields of the template are the same for every type.
+		if err := g.docTmpl.Execute(io.Discard, methodDoc{}); err != nil {
+			return nil, fmt.Errorf("expanding the method comment template: %w", err)
+		}
+	}
+	if g.methodTmpl != nil {
+		for _, obj := range objs {
+			if name, err := g.expandMethodName(obj); err != nil {
+				return nil, fmt.Errorf("expanding the method name template for %s: %w", obj.Obj().Name(), err)
+			} else if !token.IsIdentifier(name) {
+				return nil, fmt.Errorf("the method name template expands to %q for %s, which is not an identifier", name, obj.Obj().Name())
+			}
+		}
+	}
+
+	if g.ptrVariant && g.isPtrRecv {
+		return nil, errors.New("the pointer variant requires value receivers")
+	}
+
+	if g.reuseMaps {
+		switch {
+		case !g.copyInto:
+			return nil, errors.New("maps can only be reused by copies into a destination")
+		case !clearsMaps(p):
+			g.warnf("WARNING: the module of %s doesn't require Go 1.21, which declares clear. the maps of the destination are allocated anew", p.PkgPath)
+		case g.scope != nil && g.scope.Lookup("clear") != nil:
+			return nil, fmt.Errorf("%q in %q shadows a builtin used by the generated code", "clear", p.Name)
+		}
+	}
+
+	if g.fallible {
+		switch {
+		case g.copyInto:
+			return nil, errors.New("fallible methods can not copy into a destination")
+		case g.helperAt > 0:
+			return nil, errors.New("fallible methods can not copy with helper functions")
+		case g.copierName != "":
+			return nil, errors.New("the copier interface requires methods returning the copy only")
+		case g.ifaces == SwitchInterfaces:
+			return nil, errors.New("fallible methods can not copy interface values through a type switch")
+		}
+	}
+
+	if len(g.results) > 0 {
+		if err := g.checkResultTypes(objs, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if g.standalone {
+		if g.copierName != "" {
+			return nil, errors.New("the copier interface requires methods, not standalone functions")
+		}
+	} else {
+		for _, obj := range objs {
+			if err := checkAlias(obj); err != nil {
+				return nil, err
+			}
+			if err := g.checkMethodName(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if g.transitive {
+		objs = g.addCompanions(objs)
+	}
+
+	return objs, nil
+}
+
+// resultType returns the name of the type the copy of obj, named kind, is
+// returned as, and whether it is another type.
+func (g Generator) resultType(obj object, kind string) (string, bool) {
+	if r, ok := g.results[obj.Obj().Name()]; ok {
+		return r, true
+	}
+
+	return kind, false
+}
+
+// checkResultTypes returns an error if the copies of objs can not be
+// returned as their result types.
+func (g Generator) checkResultTypes(objs []object, p *packages.Package) error {
+	switch {
+	case g.copyInto:
+		return errors.New("result types can not be copied into a destination")
+	case g.ptrVariant:
+		return errors.New("result types can not be returned by the pointer variant")
+	case g.copierName != "":
+		return errors.New("the copier interface requires methods returning the copy of the type itself")
+	}
+
+	for _, obj := range objs {
+		r, ok := g.results[obj.Obj().Name()]
+		if !ok {
+			continue
+		}
+
+		if named, ok := obj.(*types.Named); ok && named.TypeParams().Len() > 0 {
+			return fmt.Errorf("the copy of the generic type %s can not be returned as %s", obj.Obj().Name(), r)
+		}
+
+		// The result type may be declared in another output package.
+		if g.scope == nil {
+			continue
+		}
+		rt, ok := g.scope.Lookup(r).(*types.TypeName)
+		if !ok {
+			return fmt.Errorf("result type %s of %s not found in %q", r, obj.Obj().Name(), p.Name)
+		}
+		if !types.ConvertibleTo(obj, rt.Type()) {
+			return fmt.Errorf("%s can not be converted to its result type %s", obj.Obj().Name(), r)
+		}
+	}
+
+	return nil
+}
+
+// declarations returns the package level declarations of the generated code,
+// the allocation counter and the copier interface.
+func (g Generator) declarations(objs []object) []decl {
+	var decls []decl
+
+	if g.allocCount != "" {
+		imports := g.imports.scoped()
+		imports.add("sync/atomic", "atomic", nil)
+		decls = append(decls, decl{[]byte(fmt.Sprintf(`// %s counts the allocations made by the generated %s methods.
+var %s atomic.Int64`, g.allocCount, g.methodName, g.allocCount)), imports})
+	}
+
+	if g.copierName != "" {
+		decls = append(decls, decl{g.generateCopier(objs), g.imports.scoped()})
+	}
+
+	return decls
+}
+
+// generateType returns the generated methods of obj, the i-th of the
+// generated types.
+func (g Generator) generateType(p *packages.Package, i int, obj object, generating []object) ([]decl, error) {
+	sels := selectors{
+		skips:    expandPromoted(g.skipLists.Get(i), obj),
+		keys:     expandPromoted(g.keyLists.Get(i), obj),
+		resets:   expandPromoted(g.resetLists.Get(i), obj),
+		cows:     expandPromoted(g.cowLists.Get(i), obj),
+		includes: expandPromoted(g.inclLists.Get(i), obj),
+		oneOf:    g.oneOfLists.Get(i),
+	}
+	// The imports of each method are tracked on their own, with the
+	// names of the file.
+	f := g
+	f.imports = g.imports.scoped()
+	fn, err := f.generateFunc(p, obj, sels, generating)
+	if err != nil {
+		return nil, fmt.Errorf("generating method: %v", err)
+	}
+
+	fns := []decl{{fn, f.imports}}
+	if g.ptrVariant {
+		f.imports = g.imports.scoped()
+		fns = append(fns, decl{f.generatePtrVariant(p, obj), f.imports})
+	}
+
+	return fns, nil
+}
+
+// helperFuncs returns the helper functions generated so far, sorted by name,
+// but those already written, and marks them as written.
+func (g Generator) helperFuncs(written map[string]bool) []decl {
+	names := make([]string, 0, len(g.helpers))
+	for name := range g.helpers {
+		if !written[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fns := make([]decl, 0, len(names))
+	for _, name := range names {
+		fns = append(fns, g.helpers[name])
+		written[name] = true
+	}
+
+	return fns
+}
+
+func (g Generator) generateFunc(p *packages.Package, obj object, sels selectors, generating []object) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var ptr string
+	if g.isPtrRecv {
+		ptr = "*"
+	}
+	x := g.outputName(p)
+	kind := g.instanceName(obj, x)
+	named, ok := obj.(*types.Named)
+	generic := ok && named.TypeParams().Len() > 0
+
+	source, sink := g.localName("o"), g.localName("cp")
+	method := g.methodFor(obj)
+	name := method
+	if g.standalone {
+		name = g.funcName(obj)
+	}
+	rkind, retyped := g.resultType(obj, kind)
+	result, nilResult := ptr+rkind, "nil"
+	if g.fallible {
+		result, nilResult = "("+result+", error)", "nil, nil"
+		if g.isPtrRecv {
+			g.errReturn = "return nil, err"
+		} else {
+			g.errReturn = "return " + sink + ", err"
+		}
+	}
+
+	if g.docTmpl != nil {
+		writeDoc(&buf, g.docTmpl, methodDoc{Method: name, Type: ptr + kind, Result: result})
+	} else if retyped {
+		fmt.Fprintf(&buf, "// %s generates a deep copy of %s%s, as %s\n", name, ptr, kind, result)
+	} else {
+		fmt.Fprintf(&buf, "// %s generates a deep copy of %s%s\n", name, ptr, kind)
+	}
+	if g.sourceRefs && p.Fset != nil {
+		pos := p.Fset.Position(obj.Obj().Pos())
+		fmt.Fprintf(&buf, "//\n// source: %s:%d\n", filepath.Base(pos.Filename), pos.Line)
+	}
+	if len(sels.includes) > 0 {
+		writeSelectors(&buf, "includes", sels.includes)
+	}
+	if len(sels.skips) > 0 {
+		writeSelectors(&buf, "skips", sels.skips)
+	}
+	if g.manifest {
+		writeManifest(&buf, obj)
+	}
+	g.writeNolint(&buf)
+	if g.standalone {
+		fmt.Fprintf(&buf, "func %s%s(%s %s%s) %s {\n", name, g.typeParams(obj, x), source, ptr, kind, result)
+	} else {
+		fmt.Fprintf(&buf, "func (%s %s%s) %s() %s {\n", source, ptr, kind, method, result)
+	}
+	if g.isPtrRecv && g.nilSafe {
+		fmt.Fprintf(&buf, "if %s == nil {\nreturn %s\n}\n", source, nilResult)
+	}
+	if !g.isPtrRecv && hasLock(obj) {
+		g.warnf("WARNING: %s holds a lock, which is copied along with the value it is called on. use a pointer receiver", kind)
+	}
+
+	if g.copyInto {
+		return g.generateIntoFunc(buf, p, obj, kind, sels, generating)
+	}
+
+	if !generic && g.maxDepth == 0 && g.isRecursive(obj, generating) {
+		if g.fallible {
+			return nil, fmt.Errorf("fallible copies of the recursive type %s require a max depth", kind)
+		}
+		if retyped {
+			return nil, fmt.Errorf("copies of the recursive type %s as %s require a max depth", kind, rkind)
+		}
+		return g.generateCycleFunc(buf, p, obj, sels, generating)
+	}
+
+	if g.startsEmpty(obj, x) {
+		fmt.Fprintf(&buf, "var %s %s\n", sink, rkind)
+	} else if retyped {
+		fmt.Fprintf(&buf, "var %s %s = %s(%s%s)\n", sink, rkind, rkind, ptr, source)
+	} else {
+		fmt.Fprintf(&buf, "var %s %s = %s%s\n", sink, kind, ptr, source)
+	}
+
+	if len(sels.oneOf) > 0 {
+		if err := g.checkOneOf(source, obj, sels.oneOf, &buf); err != nil {
+			return nil, err
+		}
+	}
+
+	g.walkType(source, sink, x, obj, &buf, make(path, 0, 8), sels, generating, 0)
+
+	var errResult string
+	if g.fallible {
+		errResult = ", nil"
+	}
+	if g.isPtrRecv {
+		fmt.Fprintf(&buf, "return &%s%s\n}", sink, errResult)
+	} else {
+		fmt.Fprintf(&buf, "return %s%s\n}", sink, errResult)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (g Generator) generateFile(w io.Writer, p *packages.Package) error {
+	var file bytes.Buffer
+
+	expr, err := g.buildConstraint()
+	if err != nil {
+		return err
+	}
+	if expr != nil {
+		lines, err := constraint.PlusBuildLines(expr)
+		if err != nil {
+			return fmt.Errorf("build constraint %s: %w", expr, err)
+		}
+		fmt.Fprintf(&file, "//go:build %s\n%s\n\n", expr, strings.Join(lines, "\n"))
+	}
+
+	fmt.Fprintf(&file, "// Code generated by deep-copy %s; DO NOT EDIT.\n\n", strings.Join(os.Args[1:], " "))
+
+	if g.packageDoc != "" && !hasPackageDoc(p) {
+		for _, line := range strings.Split(strings.TrimSpace(g.packageDoc), "\n") {
+			file.WriteString(strings.TrimSpace("// " + line))
+			file.WriteString("\n")
+		}
+	}
+
+	fmt.Fprintf(&file, "package %s\n\n", g.outputName(p))
+
+	// The file imports the packages used by its declarations.
+	imports := g.imports.scoped()
+	for _, fn := range g.fns {
+		imports.merge(fn.imports)
+	}
+	imports.writeTo(&file)
+
+	for _, fn := range g.fns {
+		file.Write(fn.code)
+		file.WriteString("\n\n")
+	}
+
+	b, err := format.Source(file.Bytes())
+	if err != nil {
+		return fmt.Errorf("error formatting source: %w\nsource:\n%s", err, file.String())
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// buildConstraint returns the build constraint of the generated file, which
+// requires the constraint given with WithBuildConstraint and each build tag,
+// or nil if there are none.
+func (g Generator) buildConstraint() (constraint.Expr, error) {
+	var expr constraint.Expr
+	for _, line := range append([]string{g.constraint}, g.buildTags...) {
+		if line == "" {
+			continue
+		}
+		x, err := constraint.Parse("//go:build " + line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid build constraint %q: %w", line, err)
+		}
+		if expr == nil {
+			expr = x
+		} else {
+			expr = &constraint.AndExpr{X: expr, Y: x}
+		}
+	}
+	return expr, nil
+}
+
+// generateCycleFunc completes the method of the recursive type obj, in buf,
+// with a call to a method copying each pointer to obj once, so cyclic values
+// are copied into the same cycle instead of endlessly.
+func (g Generator) generateCycleFunc(buf bytes.Buffer, p *packages.Package, obj object, sels selectors, generating []object) ([]byte, error) {
+	kind := g.typeName(obj, g.outputName(p))
+	source, sink := g.localName("o"), g.localName("cp")
+	g.cycle = g.cycleGroup(obj, generating)
+
+	if g.isPtrRecv {
+		fmt.Fprintf(&buf, `%s := new(%s)
+	%s
+	return %s
+}
+`, sink, kind, g.visitedCall(obj, source, sink, fmt.Sprintf("%s{%s: %s}", g.visitedType(kind), source, sink)), sink)
+	} else {
+		// The method is called on the addressable receiver as is.
+		ref := source
+		if g.standalone {
+			ref = "&" + source
+		}
+		fmt.Fprintf(&buf, `var %s %s
+	%s
+	return %s
+}
+`, sink, kind, g.visitedCall(obj, ref, "&"+sink, g.visitedType(kind)+"{}"), sink)
+	}
+
+	if err := g.generateVisitedFunc(&buf, p, obj, sels, generating); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// generateVisitedFunc writes the method copying each pointer to the
+// recursive type obj once, along with the visited pointers, to buf. The
+// cycle group of obj is set by the caller.
+func (g Generator) generateVisitedFunc(buf *bytes.Buffer, p *packages.Package, obj object, sels selectors, generating []object) error {
+	x := g.outputName(p)
+	kind := g.typeName(obj, x)
+	source, sink := g.localName("o"), g.localName("cp")
+	visitedFn, visited := g.visitedMethod(obj), g.localName("visited")
+
+	fmt.Fprintf(buf, "\n// %s copies %s into %s, reusing the copies of the pointers in %s.\n", visitedFn, source, sink, visited)
+	g.writeNolint(buf)
+	if g.standalone {
+		fmt.Fprintf(buf, "func %s(%s, %s *%s, %s %s) {\n", visitedFn, source, sink, kind, visited, g.visitedType(kind))
+	} else {
+		fmt.Fprintf(buf, "func (%s *%s) %s(%s *%s, %s %s) {\n", source, kind, visitedFn, sink, kind, visited, g.visitedType(kind))
+	}
+	if g.startsEmpty(obj, x) {
+		fmt.Fprintf(buf, "*%s = %s{}\n", sink, kind)
+	} else {
+		fmt.Fprintf(buf, "*%s = *%s\n", sink, source)
+	}
+
+	if len(sels.oneOf) > 0 {
+		if err := g.checkOneOf(source, obj, sels.oneOf, buf); err != nil {
+			return err
+		}
+	}
+
+	g.walkType(source, sink, x, obj, buf, make(path, 0, 8), sels, generating, 0)
+	fmt.Fprintf(buf, "}")
+
+	return nil
+}
+
+// generateIntoFunc completes the method of obj, in buf, with a call to a
+// method copying the value into a destination given by the caller, which it
+// declares as well.
+func (g Generator) generateIntoFunc(buf bytes.Buffer, p *packages.Package, obj object, kind string, sels selectors, generating []object) ([]byte, error) {
+	x := g.outputName(p)
+	source, sink, dst := g.localName("o"), g.localName("cp"), g.localName("dst")
+	into := g.intoName(obj)
+
+	if g.isPtrRecv {
+		fmt.Fprintf(&buf, `%s := new(%s)
+	%s
+	return %s
+}
+`, sink, kind, g.intoCall(obj, source, sink), sink)
+	} else {
+		// The method is called on the addressable receiver as is.
+		ref := source
+		if g.standalone {
+			ref = "&" + source
+		}
+		fmt.Fprintf(&buf, `var %s %s
+	%s
+	return %s
+}
+`, sink, kind, g.intoCall(obj, ref, "&"+sink), sink)
+	}
+
+	fmt.Fprintf(&buf, "\n// %s copies %s deeply into %s.\n", into, source, dst)
+	g.writeNolint(&buf)
+	if g.standalone {
+		fmt.Fprintf(&buf, "func %s%s(%s, %s *%s) {\n", into, g.typeParams(obj, x), source, dst, kind)
+	} else {
+		fmt.Fprintf(&buf, "func (%s *%s) %s(%s *%s) {\n", source, kind, into, dst, kind)
+	}
+
+	if kind == g.typeName(obj, x) && g.maxDepth == 0 && g.isRecursive(obj, generating) {
+		g.cycle = g.cycleGroup(obj, generating)
+		fmt.Fprintf(&buf, "%s\n}\n", g.visitedCall(obj, source, dst, fmt.Sprintf("%s{%s: %s}", g.visitedType(kind), source, dst)))
+		if err := g.generateVisitedFunc(&buf, p, obj, sels, generating); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	_, isStruct := obj.Underlying().(*types.Struct)
+
+	// Fields are selected through the pointers, other values are assigned
+	// to and read from the pointed values.
+	esource, edst := source, dst
+	if !isStruct {
+		esource, edst = "(*"+source+")", "(*"+dst+")"
+	}
+
+	// The maps of the destination are kept aside before it is overwritten,
+	// to be refilled by the copy.
+	var body bytes.Buffer
+	if g.reuseMaps && clearsMaps(p) {
+		g.reusedMaps = new([]string)
+	}
+	g.walkType(esource, edst, x, obj, &body, make(path, 0, 8), sels, generating, 0)
+	if g.reusedMaps != nil {
+		for _, sink := range *g.reusedMaps {
+			fmt.Fprintf(&buf, "%s := %s\n", reusedName(sink), sink)
+		}
+	}
+
+	if g.startsEmpty(obj, x) {
+		fmt.Fprintf(&buf, "*%s = %s{}\n", dst, kind)
+	} else {
+		fmt.Fprintf(&buf, "*%s = *%s\n", dst, source)
+	}
+
+	if len(sels.oneOf) > 0 {
+		if err := g.checkOneOf(source, obj, sels.oneOf, &buf); err != nil {
+			return nil, err
+		}
+	}
+
+	body.WriteTo(&buf)
+	fmt.Fprintf(&buf, "}")
+
+	return buf.Bytes(), nil
+}
+
+// writeNolint writes the //nolint directive exempting the following
+// function from the linters, if any.
+func (g Generator) writeNolint(w io.Writer) {
+	if len(g.nolint) > 0 {
+		fmt.Fprintf(w, "//nolint:%s\n", strings.Join(g.nolint, ","))
+	}
+}
+
+// writeManifest writes the fields of the struct type obj, with their types,
+// as a comment.
+func writeManifest(w io.Writer, obj object) {
+	st, ok := obj.Underlying().(*types.Struct)
+	if !ok || st.NumFields() == 0 {
+		return
+	}
+
+	qualifier := func(p *types.Package) string {
+		if p == obj.Obj().Pkg() {
+			return ""
+		}
+		return p.Name()
+	}
+
+	fmt.Fprintf(w, "//\n// fields:\n//\n")
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		fmt.Fprintf(w, "//\t%s %s\n", field.Name(), types.TypeString(field.Type(), qualifier))
+	}
+}
+
+// methodDoc is the data of the method comment template.
+type methodDoc struct {
+	Method string
+	Type   string
+	Result string
+}
+
+// writeDoc writes the comment expanded from tmpl, commenting out each line.
+func writeDoc(w io.Writer, tmpl *template.Template, doc methodDoc) {
+	var b strings.Builder
+	// The template was checked to expand in prepare.
+	_ = tmpl.Execute(&b, doc)
+
+	for _, line := range strings.Split(strings.TrimRight(b.String(), "\n"), "\n") {
+		fmt.Fprintln(w, strings.TrimSpace("// "+line))
+	}
+}
+
+// writeSelectors lists the included or skipped selectors, sorted, in the
+// comment of a method, so the omissions of the copy are visible in review.
+func writeSelectors(w io.Writer, label string, s skips) {
+	sels := make([]string, 0, len(s))
+	for sel := range s {
+		sels = append(sels, sel)
+	}
+	sort.Strings(sels)
+
+	fmt.Fprintf(w, "//\n// %s: %s\n", label, strings.Join(sels, ", "))
+}
+
+// generatePtrVariant generates the variant of the value receiver method of
+// obj with a pointer receiver, or of the function in standalone mode.
+func (g Generator) generatePtrVariant(p *packages.Package, obj object) []byte {
+	var buf bytes.Buffer
+
+	x := g.outputName(p)
+	kind := g.instanceName(obj, x)
+	source, sink := g.localName("o"), g.localName("cp")
+	name, call := g.methodFor(obj)+"Ptr", source+"."+g.methodFor(obj)+"()"
+
+	result, nilResult := "*"+kind, "nil"
+	if g.fallible {
+		result, nilResult = "(*"+kind+", error)", "nil, nil"
+	}
+
+	fmt.Fprintf(&buf, "// %s generates a deep copy of *%s\n", name, kind)
+	if g.standalone {
+		name, call = name+obj.Obj().Name(), g.funcName(obj)+"(*"+source+")"
+		fmt.Fprintf(&buf, "func %s%s(%s *%s) %s {\n", name, g.typeParams(obj, x), source, kind, result)
+	} else {
+		fmt.Fprintf(&buf, "func (%s *%s) %s() %s {\n", source, kind, name, result)
+	}
+	if g.nilSafe {
+		fmt.Fprintf(&buf, "if %s == nil {\nreturn %s\n}\n", source, nilResult)
+	}
+
+	if g.fallible {
+		fmt.Fprintf(&buf, `%s, err := %s
+	if err != nil {
+		return nil, err
+	}
+	return &%s, nil
+}`, sink, call, sink)
+	} else {
+		fmt.Fprintf(&buf, `%s := %s
+	return &%s
+}`, sink, call, sink)
+	}
+
+	return buf.Bytes()
+}
+
+// unnamedPointer returns source, a pointer of type t, converted to the
+// unnamed pointer type if t is a defined pointer type, e.g. type Ref *T,
+// whose method set is empty even if *T has methods.
+func (g Generator) unnamedPointer(source string, t types.Type, x string) string {
+	if _, ok := t.(*types.Named); !ok {
+		return source
+	}
+
+	return "(" + g.getElemType(t.Underlying(), x) + ")(" + source + ")"
+}
+
+// instanceName returns the name of the generated type obj in the package x,
+// instantiated with its own type parameters, e.g. Foo[T].
+func (g Generator) instanceName(obj object, x string) string {
+	kind := g.typeName(obj, x)
+	if named, ok := obj.(*types.Named); ok && named.TypeParams().Len() > 0 {
+		params := make([]string, named.TypeParams().Len())
+		for i := range params {
+			params[i] = named.TypeParams().At(i).Obj().Name()
+		}
+		kind += "[" + strings.Join(params, ", ") + "]"
+	}
+
+	return kind
+}
+
+// intoName returns the name of the method copying values of obj into a
+// given destination, or of the function in standalone mode.
+func (g Generator) intoName(obj object) string {
+	if g.standalone {
+		return g.funcName(obj) + "Into"
+	}
+	return g.methodFor(obj) + "Into"
+}
+
+// intoCall returns the call copying the pointer source to obj into the
+// pointer dst.
+func (g Generator) intoCall(obj object, source, dst string) string {
+	if g.standalone {
+		return fmt.Sprintf("%s(%s, %s)", g.intoName(obj), source, dst)
+	}
+	return fmt.Sprintf("%s.%s(%s)", source, g.intoName(obj), dst)
+}
+
+// clearsMaps reports whether the module of p requires Go 1.21, which
+// declares clear.
+func clearsMaps(p *packages.Package) bool {
+	return p.Module != nil && p.Module.GoVersion != "" && version.Compare("go"+p.Module.GoVersion, "go1.21") >= 0
+}
+
+// reusedName returns the name of the variable keeping the map of the
+// destination sink aside.
+func reusedName(sink string) string {
+	return selToIdent(sink) + "_prev"
+}
+
+// visitedMethod returns the name of the method copying the recursive type
+// obj along with the visited pointers, or of the function in standalone mode.
+func (g Generator) visitedMethod(obj object) string {
+	name := g.methodFor(obj)
+	if g.standalone {
+		name = g.funcName(obj)
+	}
+	method := []rune(name)
+	method[0] = unicode.ToLower(method[0])
+	return string(method) + "Visited"
+}
+
+// visitedCall returns the call copying the pointer source to obj into the
+// pointer sink, along with the visited pointers.
+func (g Generator) visitedCall(obj object, source, sink, visited string) string {
+	if g.standalone {
+		return fmt.Sprintf("%s(%s, %s, %s)", g.visitedMethod(obj), source, sink, visited)
+	}
+	return fmt.Sprintf("%s.%s(%s, %s)", source, g.visitedMethod(obj), sink, visited)
+}
+
+// funcName returns the name of the standalone function copying values of t.
+// A name expanded from the method name template is used as is.
+func (g Generator) funcName(t types.Type) string {
+	if obj := objFromType(t); obj != nil {
+		if _, ok := g.methods[obj.Obj().Name()]; !ok && g.methodTmpl != nil && g.inScope(obj) {
+			return g.methodFor(obj)
+		}
+		return g.methodFor(obj) + obj.Obj().Name()
+	}
+	return g.methodName
+}
+
+// methodFor returns the name of the method copying values of t.
+func (g Generator) methodFor(t types.Type) string {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return g.methodName
+	}
+
+	if !g.inScope(named) {
+		return g.methodName
+	}
+
+	if name, ok := g.methods[named.Obj().Name()]; ok {
+		return name
+	}
+
+	if g.methodTmpl != nil {
+		// The template was checked to expand for the generated types.
+		if name, err := g.expandMethodName(named); err == nil {
+			return name
+		}
+	}
+
+	return g.methodName
+}
+
+// inScope reports whether t is declared by the package of the generated
+// types, whose methods are named by the generator.
+func (g Generator) inScope(t object) bool {
+	return g.scope == nil || t.Obj().Parent() == g.scope
+}
+
+// expandMethodName returns the method name template expanded for obj.
+func (g Generator) expandMethodName(obj object) (string, error) {
+	var b strings.Builder
+	if err := g.methodTmpl.Execute(&b, struct{ Type string }{obj.Obj().Name()}); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// typeParams returns the type parameter list of the standalone function
+// copying values of the generic type obj, e.g. [T any].
+func (g Generator) typeParams(obj object, x string) string {
+	named, ok := obj.(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return ""
+	}
+
+	params := make([]string, named.TypeParams().Len())
+	for i := range params {
+		tp := named.TypeParams().At(i)
+		params[i] = tp.Obj().Name() + " " + g.getElemType(tp.Constraint(), x)
+	}
+
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// copyCall returns the call copying source, a value of a generated type, or
+// a pointer to one, with the generated method, or function in standalone
+// mode. The call returns a pointer if the receiver is a pointer.
+func (g Generator) copyCall(source string, t types.Type, pointer bool) string {
+	if !g.standalone {
+		return source + "." + g.methodFor(t) + "()"
+	}
+
+	switch {
+	case pointer && !g.isPtrRecv:
+		source = "*" + source
+	case !pointer && g.isPtrRecv:
+		source = "&" + source
+	}
+
+	return g.funcName(t) + "(" + source + ")"
+}
+
+// isRecursive reports whether values of obj can point to values of obj,
+// through fields or elements which are copied inline, or by the generated
+// methods of other types, e.g. A{B *B} and B{A *A}.
+func (g Generator) isRecursive(obj object, generating []object) bool {
+	return g.reaches(obj, obj, generating, true)
+}
+
+// cycleGroup returns the recursive type obj, and the generated types it is
+// mutually recursive with, whose copies share their visited pointers.
+func (g Generator) cycleGroup(obj object, generating []object) []object {
+	group := []object{obj}
+	for _, t := range generating {
+		if types.Identical(t, obj) || g.retyped(t) || isGeneric(t) {
+			continue
+		}
+		if g.reaches(obj, t, generating, false) && g.reaches(t, obj, generating, false) {
+			group = append(group, t)
+		}
+	}
+
+	return group
+}
+
+// reaches reports whether values of from can hold values of to, through
+// fields or elements which are copied inline, or by generated methods, and
+// through a pointer if viaPointer is set.
+func (g Generator) reaches(from, to object, generating []object, viaPointer bool) bool {
+	type step struct {
+		t       types.Type
+		pointed bool
+	}
+	seen := map[step]bool{}
+
+	var walk func(t types.Type, pointed bool) bool
+	walk = func(t types.Type, pointed bool) bool {
+		switch v := t.(type) {
+		case *types.Named:
+			if types.Identical(v, to) {
+				return pointed || !viaPointer
+			}
+			if seen[step{v, pointed}] {
+				return false
+			}
+			seen[step{v, pointed}] = true
+			if hasMethod, _ := g.hasDeepCopy(v, generating); hasMethod && (!g.isGenerated(v, generating) || isGeneric(v)) {
+				return false
+			}
+			return walk(v.Underlying(), pointed)
+		case *types.Pointer:
+			return walk(v.Elem(), true)
+		case *types.Struct:
+			for i := 0; i < v.NumFields(); i++ {
+				if walk(v.Field(i).Type(), pointed) {
+					return true
+				}
+			}
+		case *types.Slice:
+			return walk(v.Elem(), pointed)
+		case *types.Array:
+			return walk(v.Elem(), pointed)
+		case *types.Map:
+			return walk(v.Key(), pointed) || walk(v.Elem(), pointed)
+		}
+
+		return false
+	}
+
+	return walk(from.Underlying(), false)
+}
+
+// isGeneric reports whether t is a generic type, or an instance of one.
+func isGeneric(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && (named.TypeParams().Len() > 0 || named.TypeArgs().Len() > 0)
+}
+
+// inCycle returns the type of the cycle group which t is, if any.
+func (g Generator) inCycle(t types.Type) (object, bool) {
+	for _, obj := range g.cycle {
+		if types.Identical(t, obj) {
+			return obj, true
+		}
+	}
+
+	return nil, false
+}
+
+// visitedType returns the type of the visited map of the copy of the cycle
+// group, with obj spelled as kind: the pointers to obj alone, or to any type
+// of the group.
+func (g Generator) visitedType(kind string) string {
+	if len(g.cycle) > 1 {
+		return "map[any]any"
+	}
+	return fmt.Sprintf("map[*%s]*%s", kind, kind)
+}
+
+// checkAlias returns an error if obj is an alias of a type on which methods
+// can't be declared, i.e. not a defined type of the same package.
+func checkAlias(obj object) error {
+	alias, ok := obj.(*types.Alias)
+	if !ok {
+		return nil
+	}
+
+	named, ok := types.Unalias(alias).(*types.Named)
+	if !ok || named.Obj().Pkg() != alias.Obj().Pkg() {
+		return fmt.Errorf("%s is an alias of %s, which can not have methods; generate standalone functions instead", alias.Obj().Name(), types.TypeString(types.Unalias(alias), types.RelativeTo(alias.Obj().Pkg())))
+	}
+
+	return nil
+}
+
+// checkMethodName returns an error if obj declares a field, or a method of
+// another signature, with the name of the generated method.
+func (g Generator) checkMethodName(obj object) error {
+	method := g.methodFor(obj)
+	found, index, _ := types.LookupFieldOrMethod(obj, true, obj.Obj().Pkg(), method)
+	if found == nil || len(index) > 1 {
+		// Promoted fields and methods are shadowed by the generated method.
+		return nil
+	}
+
+	kind := obj.Obj().Name()
+	switch v := found.(type) {
+	case *types.Var:
+		return fmt.Errorf("%s has a field named %s, which collides with the generated method; choose another method name, e.g. %q", kind, method, g.alternateMethodName(obj))
+	case *types.Func:
+		sig := v.Type().(*types.Signature)
+		if sig.Params().Len() == 0 && sig.Results().Len() == 1 {
+			retType, _ := reducePointer(sig.Results().At(0).Type())
+			recvType, _ := reducePointer(sig.Recv().Type())
+			if types.Identical(retType, recvType) {
+				// Most likely generated before, and about to be replaced.
+				return nil
+			}
+		}
+		return fmt.Errorf("%s has a method %s%s, which collides with the generated method; choose another method name, e.g. %q", kind, method, strings.TrimPrefix(types.TypeString(sig, types.RelativeTo(obj.Obj().Pkg())), "func"), g.alternateMethodName(obj))
+	}
+
+	return nil
+}
+
+// alternateMethodName suggests a method name that obj does not use yet.
+func (g Generator) alternateMethodName(obj object) string {
+	method := g.methodFor(obj)
+	for _, name := range []string{"DeepCopy", "Clone", "DeepClone", method + "Value"} {
+		if found, _, _ := types.LookupFieldOrMethod(obj, true, obj.Obj().Pkg(), name); found == nil {
+			return name
+		}
+	}
+
+	return method + "Value"
+}
+
+// generateCopier declares the copier interface, and asserts that the
+// non-generic types implement it.
+func (g Generator) generateCopier(objs []object) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `// %s is implemented by the types with a generated %s method.
+type %s[T any] interface {
+	%s() T
+}
+`, g.copierName, g.methodName, g.copierName, g.methodName)
+
+	var ptr string
+	if g.isPtrRecv {
+		ptr = "*"
+	}
+
+	for _, obj := range objs {
+		if named, ok := obj.(*types.Named); ok && named.TypeParams().Len() > 0 {
+			continue
+		}
+
+		if g.methodFor(obj) != g.methodName {
+			continue
+		}
+
+		kind := obj.Obj().Name()
+		fmt.Fprintf(&buf, "\nvar _ %s[%s%s] = (*%s)(nil)", g.copierName, ptr, kind, kind)
+	}
+
+	return buf.Bytes()
+}
+
+// helper returns the name of the function copying values of t, generating
+// it if needed, when t is a struct type of the generated package nested at
+// least helperAt levels deep. Values whose copy is adjusted by selectors are
+// inlined, since the helper is shared by all of them.
+func (g Generator) helper(t types.Type, x string, sel path, sels selectors, generating []object, depth int) (string, bool) {
+	if g.helperAt <= 0 || depth < g.helperAt || g.helpers == nil {
+		return "", false
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Name() != x || named.TypeArgs().Len() > 0 {
+		return "", false
+	}
+
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return "", false
+	}
+
+	if v, ok := t.(methoder); ok {
+		if hasMethod, _ := g.hasDeepCopy(v, generating); hasMethod || hasLock(t) {
+			return "", false
+		}
+	}
+
+	if sels.within(sel) {
+		return "", false
+	}
+
+	method := []rune(g.methodName)
+	method[0] = unicode.ToLower(method[0])
+	name := g.localName(string(method) + named.Obj().Name())
+	if _, ok := g.helpers[name]; ok {
+		return name, true
+	}
+
+	// The name is reserved before generating the body, so recursive types
+	// call the helper instead of expanding endlessly.
+	g.helpers[name] = decl{}
+	g.imports = g.imports.scoped()
+
+	var buf bytes.Buffer
+	kind := named.Obj().Name()
+	source, sink := g.localName("o"), g.localName("cp")
+	fmt.Fprintf(&buf, "// %s generates a deep copy of %s\n", name, kind)
+	g.writeNolint(&buf)
+	fmt.Fprintf(&buf, `func %s(%s %s) %s {
+	var %s %s = %s
+`, name, source, kind, kind, sink, kind, source)
+	g.walkType(source, sink, x, named, &buf, make(path, 0, 8), selectors{}, generating, 0)
+	fmt.Fprintf(&buf, "return %s\n}", sink)
+
+	g.helpers[name] = decl{buf.Bytes(), g.imports}
+
+	return name, true
+}
+
+// within reports whether a selector of the skips, copied keys, resets,
+// copy-on-write or included fields selects a value nested in p.
+func (s selectors) within(p path) bool {
+	prefix := string(p.appendCanonical(nil))
+	for _, set := range []skips{s.skips, s.keys, s.resets, s.cows, s.includes} {
+		for sel := range set {
+			if nestedSelector(sel, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// excludes reports whether the include list leaves p out of the deep copy,
+// as p is neither included, nested in an included selector, nor leads to one.
+func (s selectors) excludes(p path) bool {
+	if len(s.includes) == 0 || len(p) == 0 {
+		return false
+	}
+
+	sel := string(p.appendCanonical(nil))
+	for incl := range s.includes {
+		if sel == incl || nestedSelector(sel, incl) || nestedSelector(incl, sel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rebased returns s with each selector nested in p, e.g. Map[].Slice in
+// Map[], also selecting relative to p, e.g. Slice, as the values of a map
+// are walked from their own path. They are then matched both ways.
+func (s selectors) rebased(p path) selectors {
+	prefix := string(p.appendCanonical(nil))
+	rebase := func(set skips) skips {
+		var rel skips
+		for sel := range set {
+			if !nestedSelector(sel, prefix) {
+				continue
+			}
+			if rel == nil {
+				rel = make(skips, len(set))
+				for sel := range set {
+					rel[sel] = struct{}{}
+				}
+			}
+			rel[strings.TrimPrefix(sel[len(prefix):], ".")] = struct{}{}
+		}
+		if rel == nil {
+			return set
+		}
+
+		return rel
+	}
+
+	s.skips, s.keys, s.resets, s.cows, s.includes = rebase(s.skips), rebase(s.keys), rebase(s.resets), rebase(s.cows), rebase(s.includes)
+
+	return s
+}
+
+// nestedSelector reports whether the canonical selector sel selects a value
+// nested in the one of prefix, e.g. A.B or A[] in A.
+func nestedSelector(sel, prefix string) bool {
+	return strings.HasPrefix(sel, prefix) && len(sel) > len(prefix) && (sel[len(prefix)] == '.' || sel[len(prefix)] == '[')
+}
+
+// expandPromoted returns the canonical selectors of s, adding the selector
+// through the embedded fields of each selector naming a promoted field of
+// obj, e.g. Inner.X for X, as the copy walks the embedded fields by their
+// type name.
+func expandPromoted(s skips, obj object) skips {
+	if len(s) == 0 {
+		return s
+	}
+
+	expanded := make(skips, len(s))
+	for sel := range s {
+		expanded[canonicalSelector(sel)] = struct{}{}
+		if full, ok := embeddedPath(sel, obj, obj.Obj().Pkg()); ok {
+			expanded[canonicalSelector(full)] = struct{}{}
+		}
+	}
+
+	return expanded
+}
+
+// embeddedPath returns sel with the embedded fields its promoted fields are
+// selected through, resolved in t, and whether sel selects any.
+func embeddedPath(sel string, t types.Type, pkg *types.Package) (string, bool) {
+	var p path
+	var promoted bool
+
+	for _, seg := range splitSelector(sel) {
+		if ptr, ok := t.Underlying().(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+
+		if strings.HasPrefix(seg, "[") {
+			switch v := t.Underlying().(type) {
+			case *types.Slice:
+				t = v.Elem()
+			case *types.Array:
+				t = v.Elem()
+			case *types.Map:
+				t = v.Elem()
+			default:
+				return "", false
+			}
+			p = append(p, seg)
+			continue
+		}
+
+		obj, index, _ := types.LookupFieldOrMethod(t, true, pkg, seg)
+		field, ok := obj.(*types.Var)
+		if !ok || !field.IsField() {
+			return "", false
+		}
+
+		// Every index but the last selects an embedded field.
+		embedding := t
+		for _, i := range index[:len(index)-1] {
+			if ptr, ok := embedding.Underlying().(*types.Pointer); ok {
+				embedding = ptr.Elem()
+			}
+			embedded := embedding.Underlying().(*types.Struct).Field(i)
+			p = append(p, embedded.Name())
+			embedding = embedded.Type()
+			promoted = true
+		}
+
+		p = append(p, seg)
+		t = field.Type()
+	}
+
+	return p.String(), promoted
+}
+
+// splitSelector splits sel into the segments of a path.
+func splitSelector(sel string) path {
+	var p path
+	for _, part := range strings.Split(sel, ".") {
+		for {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				p = append(p, part[:i])
+			}
+			end := strings.Index(part, "]")
+			if end < i {
+				break
+			}
+			p = append(p, part[i:end+1])
+			part = part[end+1:]
+		}
+		if part != "" {
+			p = append(p, part)
+		}
+	}
+
+	return p
+}
+
+// countAlloc writes the increment of the allocation counter, if any.
+func (g Generator) countAlloc(w io.Writer) {
+	if g.allocCount != "" {
+		fmt.Fprintf(w, "%s.Add(1)\n", g.allocCount)
+	}
+}
+
+// checkOneOf writes a check that exactly one of the given fields of the
+// source is set.
+func (g Generator) checkOneOf(source string, obj object, fields skips, w io.Writer) error {
+	st, ok := obj.Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("%s is not a struct, it has no union fields", obj.Obj().Name())
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		var field *types.Var
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Name() == name {
+				field = st.Field(i)
+				break
+			}
+		}
+
+		if field == nil {
+			return fmt.Errorf("%s has no field %s", obj.Obj().Name(), name)
+		}
+
+		switch field.Type().Underlying().(type) {
+		case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		default:
+			return fmt.Errorf("union field %s.%s can not be nil", obj.Obj().Name(), name)
+		}
+
+		fmt.Fprintf(&b, "if %s.%s != nil {\nset++\n}\n", source, name)
+	}
+
+	fmt.Fprintf(w, `{
+	set := 0
+	%sif set != 1 {
+		panic("exactly one of %s of %s must be set")
+	}
+}
+`, b.String(), strings.Join(names, ", "), obj.Obj().Name())
+
+	return nil
+}
+
+// warnf logs a warning to the logger, if any.
+func (g Generator) warnf(format string, args ...any) {
+	if g.logger != nil {
+		g.logger.Printf(format, args...)
+	}
+}
+
+// tracef logs, in verbose mode, the action taken on the value of type t at
+// sel, indented by depth, so the walk of a type prints as a tree.
+func (g Generator) tracef(depth int, sel path, t types.Type, action string) {
+	if !g.verbose {
+		return
+	}
+
+	name := sel.String()
+	if name == "" {
+		name = "."
+	}
+	g.warnf("%s%s %s: %s", strings.Repeat("  ", depth), name, types.TypeString(t, (*types.Package).Name), action)
+}
+
+// qualifier returns the qualifier of the types of the packages other than
+// x, importing them.
+func (g Generator) qualifier(x string) types.Qualifier {
+	return func(p *types.Package) string {
+		// Types of the package the file is generated in are unqualified.
+		if g.scope != nil && p.Scope() == g.scope || g.scope == nil && p.Name() == x {
+			return ""
+		}
+
+		return g.imports.add(p.Path(), p.Name(), func(name string) bool {
+			return g.scope != nil && g.scope.Lookup(name) != nil
+		})
+	}
+}
+
+// typeName returns the name of the generated type obj in the package x,
+// without its type parameters.
+func (g Generator) typeName(obj object, x string) string {
+	if obj.Obj().Pkg() == nil {
+		return obj.Obj().Name()
+	}
+
+	if q := g.qualifier(x)(obj.Obj().Pkg()); q != "" {
+		return q + "." + obj.Obj().Name()
+	}
+
+	return obj.Obj().Name()
+}
+
+// outputName returns the name of the package of the generated file.
+func (g Generator) outputName(p *packages.Package) string {
+	if g.pkgName != "" {
+		return g.pkgName
+	}
+
+	return p.Name
+}
+
+// generatedFiles returns the set of the generated files of the package, e.g.
+// the output of a previous run.
+func generatedFiles(p *packages.Package) map[string]bool {
+	generated := map[string]bool{}
+
+	fset := token.NewFileSet()
+	for _, name := range p.GoFiles {
+		f, err := parser.ParseFile(fset, name, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			continue
+		}
+
+		if ast.IsGenerated(f) {
+			generated[name] = true
+		}
+	}
+
+	return generated
+}
+
+// hasPackageDoc reports whether a file of the package, other than a
+// generated one, has a package doc comment.
+func hasPackageDoc(p *packages.Package) bool {
+	fset := token.NewFileSet()
+	for _, name := range p.GoFiles {
+		f, err := parser.ParseFile(fset, name, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			continue
+		}
+
+		if f.Doc != nil && !ast.IsGenerated(f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g Generator) walkType(source, sink, x string, m types.Type, w io.Writer, sel path, sels selectors, generating []object, depth int) {
+	initial := depth == 0
+	if m == nil {
+		return
+	}
+
+	// Aliases are spelled as such in the generated code, but copied as the
+	// type they denote, e.g. with its method.
+	spelled := m
+	m = types.Unalias(m)
+
+	if g.maxDepth > 0 {
+		if depth >= g.maxDepth {
+			// Values without references are fully copied already.
+			if !hasPointers(m) {
+				g.tracef(depth, sel, m, "assigned, no references")
+				return
+			}
+			g.tracef(depth, sel, m, "shallow, below max depth")
+			p := strings.Split(sink, ".")
+			stoppedAt := strings.TrimSuffix(fmt.Sprintf("%s.%s", generating[0], strings.Join(p[1:], ".")), ".")
+			g.warnf("WARNING: reached max depth %d. stop recursion at %s", depth, stoppedAt)
+			if g.truncated != nil {
+				*g.truncated = append(*g.truncated, stoppedAt)
+			}
+			if g.depthNotes {
+				fmt.Fprintf(w, "// %s: shallow copy below max depth\n", sink)
+			}
+			return
+		}
+	}
+
+	if _, ok := g.valueTypes[types.TypeString(m, nil)]; ok {
+		// The value is copied by assignment, as already done by the parent.
+		g.tracef(depth, sel, m, "assigned, value type")
+		return
+	}
+
+	if v, ok := m.(*types.TypeParam); ok {
+		// The type argument is unknown, so the value is copied with the
+		// method its constraint requires, if any, or shallowly.
+		if g.constrainedCopy(v) {
+			g.tracef(depth, sel, m, "reuse, constraint method")
+			fmt.Fprintf(w, "%s = %s.%s()\n", sink, source, g.methodName)
+			return
+		}
+		g.tracef(depth, sel, m, "shallow, type parameter")
+		if !hasCoreType(v) {
+			g.warnf("WARNING: %s has no single core type in %s. copying %s shallowly", v, types.TypeString(v.Constraint(), (*types.Package).Name), sink)
+		}
+		return
+	}
+
+	if obj, ok := g.inCycle(m); ok && !initial && len(g.cycle) > 1 {
+		// The value is copied along with the pointers visited by the copy
+		// of the type it is mutually recursive with.
+		g.tracef(depth, sel, m, "reuse, visited")
+		ref := source
+		if g.standalone {
+			ref = "&" + source
+		}
+		fmt.Fprintf(w, "%s\n", g.visitedCall(obj, ref, "&"+sink, g.localName("visited")))
+		return
+	}
+
+	if v, ok := m.(methoder); ok && !initial && !types.IsInterface(m) && g.reuseDeepCopy(source, sink, x, v, false, generating, w) {
+		g.tracef(depth, sel, m, "reuse")
+		return
+	}
+
+	if isLock(m) {
+		// Locks hold no references, and are warned about by their parent.
+		g.tracef(depth, sel, m, "skip, lock")
+		return
+	}
+
+	if name, ok := g.helper(m, x, sel, sels, generating, depth); ok {
+		g.tracef(depth, sel, m, "helper "+name)
+		fmt.Fprintf(w, "%s = %s(%s)\n", sink, name, source)
+		return
+	}
+
+	if _, ok := m.Underlying().(*types.Basic); ok {
+		g.tracef(depth, sel, m, "assigned")
+	} else {
+		g.tracef(depth, sel, m, "recurse")
+	}
+	depth++
+	under := m.Underlying()
+	reused := g.reusedMaps
+	if _, ok := under.(*types.Struct); !ok {
+		// Only the maps held by the destination itself are reused, not
+		// those of the values the copy allocates.
+		g.reusedMaps = nil
+	}
+	switch v := under.(type) {
+	case *types.Struct:
+		// The copy of the generated struct starts from its zero value in
+		// explicit mode, as does the copy of a struct holding a lock, so
+		// each of its fields is assigned.
+		explicit := initial && g.startsEmpty(m, x) || resetsLocks(m, x, sel)
+
+		for i := 0; i < v.NumFields(); i++ {
+			field := v.Field(i)
+			fname := field.Name()
+			if isLock(field.Type()) {
+				switch {
+				case explicit:
+					fmt.Fprintf(w, "// %s.%s: sync primitive reset\n", sink, fname)
+				case !accessible(field, x):
+					g.warnf("WARNING: copying %s copies the lock in its %s field. define a %s or Clone method to copy it", types.TypeString(m, (*types.Package).Name), fname, g.methodFor(m))
+				default:
+					fmt.Fprintf(w, "%s.%s = %s // sync primitive reset\n", sink, fname, g.zeroValue(field.Type(), x))
+				}
+				continue
+			}
+			if !accessible(field, x) {
+				// The field is copied along with the struct, but can't be
+				// selected outside of the package declaring it.
+				if hasPointers(field.Type()) {
+					g.warnf("WARNING: copying %s shares the references in its %s field. define a %s or Clone method to copy them", types.TypeString(m, (*types.Package).Name), fname, g.methodFor(m))
+				}
+				continue
+			}
+			fsel := append(sel, fname)
+			if sels.resets.ContainsPath(fsel) {
+				g.tracef(depth, fsel, field.Type(), "reset")
+				if !explicit {
+					fmt.Fprintf(w, "%s.%s = %s\n", sink, fname, g.zeroValue(field.Type(), x))
+				}
+				continue
+			}
+			shallow := sels.skips.ContainsPath(fsel) || g.skipsTag(reflect.StructTag(v.Tag(i))) || sels.excludes(fsel)
+			if explicit && (shallow || !g.assignsFully(field.Type(), generating) && !resetsLocks(field.Type(), x, fsel)) {
+				fmt.Fprintf(w, "%s.%s = %s.%s\n", sink, fname, source, fname)
+			}
+			if sels.cows.ContainsPath(fsel) {
+				g.tracef(depth, fsel, field.Type(), "shared, copy-on-write")
+				fmt.Fprintf(w, "// %s is shared with %s until written: copy-on-write\n", sink+"."+fname, source+"."+fname)
+				continue
+			}
+			if shallow {
+				g.tracef(depth, fsel, field.Type(), "skip")
+				continue
+			}
+			g.walkType(source+"."+fname, sink+"."+fname, x, field.Type(), w, fsel, sels, generating, depth)
+		}
+	case *types.Slice:
+		// A named slice type, e.g. json.RawMessage, is made as such.
+		kind := "[]" + g.getElemType(v.Elem(), x)
+		if _, ok := m.(*types.Named); ok || spelled != m {
+			kind = g.getElemType(spelled, x)
+		}
+
+		idx := "i"
+		if depth > 1 {
+			idx += strconv.Itoa(depth)
+		}
+		idx = g.localName(idx)
+
+		esel := append(sel, "[i]")
+
+		var skipSlice bool
+		if sels.skips.ContainsPath(esel) || sels.excludes(esel) {
+			g.tracef(depth, esel, v.Elem(), "skip")
+			skipSlice = true
+		}
+
+		fmt.Fprintf(w, `if %s != nil {
+	%s = make(%s, len(%s))
+`, source, sink, kind, source)
+		g.countAlloc(w)
+
+		fmt.Fprintf(w, `copy(%s, %s)
+`, sink, source)
+
+		var b bytes.Buffer
+
+		if !skipSlice {
+			baseSel := "[" + idx + "]"
+			g.walkType(source+baseSel, sink+baseSel, x, v.Elem(), &b, esel, sels, generating, depth)
+		}
+
+		if onlyComments(b.Bytes()) {
+			// There is nothing to copy per element, only to document.
+			b.WriteTo(w)
+		} else {
+			fmt.Fprintf(w, `    for %s := range %s {
+`, idx, source)
+
+			b.WriteTo(w)
+
+			fmt.Fprintf(w, "}\n")
+		}
+
+		fmt.Fprintf(w, "}\n")
+	case *types.Array:
+		idx := "i"
+		if depth > 1 {
+			idx += strconv.Itoa(depth)
+		}
+		idx = g.localName(idx)
+
+		esel := append(sel, "[i]")
+		if sels.skips.ContainsPath(esel) || sels.excludes(esel) {
+			g.tracef(depth, esel, v.Elem(), "skip")
+			break
+		}
+
+		// The elements were copied along with the array, only their
+		// references need a deep copy.
+		var b bytes.Buffer
+		baseSel := "[" + idx + "]"
+		g.walkType(source+baseSel, sink+baseSel, x, v.Elem(), &b, esel, sels, generating, depth)
+
+		if onlyComments(b.Bytes()) {
+			b.WriteTo(w)
+		} else {
+			fmt.Fprintf(w, "for %s := range %s {\n", idx, source)
+			b.WriteTo(w)
+			fmt.Fprintf(w, "}\n")
+		}
+	case *types.Pointer:
+		if _, ok := g.sharedPtrs[types.TypeString(v.Elem(), nil)]; ok {
+			// The pointer is shared, as already assigned by the parent.
+			break
+		}
+
+		fmt.Fprintf(w, "if %s != nil {\n", source)
+
+		if obj, ok := g.inCycle(v.Elem()); ok {
+			c, visited := g.localName("c"), g.localName("visited")
+			cp := c
+			if len(g.cycle) > 1 {
+				cp = fmt.Sprintf("%s.(*%s)", c, g.getElemType(v.Elem(), x))
+			}
+			fmt.Fprintf(w, `if %s, ok := %s[%s]; ok {
+	%s = %s
+} else {
+	%s = %s
+`, c, visited, source, sink, cp, sink, g.alloc(v.Elem(), x))
+			g.countAlloc(w)
+			fmt.Fprintf(w, `%s[%s] = %s
+	%s
+}
+`, visited, source, sink, g.visitedCall(obj, source, sink, visited))
+		} else if name, ok := g.helper(v.Elem(), x, sel, sels, generating, depth); ok {
+			fmt.Fprintf(w, `%s = %s
+	*%s = %s(*%s)
+`, sink, g.alloc(v.Elem(), x), sink, name, source)
+			g.countAlloc(w)
+		} else if e, ok := v.Elem().(methoder); !ok || initial || !g.reuseDeepCopy(g.unnamedPointer(source, m, x), sink, x, e, true, generating, w) {
+			// A slice or map is only assigned once copied, so the copy
+			// never holds the header of the source, and keeps a nil one.
+			fmt.Fprintf(w, "%s = %s\n", sink, g.alloc(v.Elem(), x))
+			if !g.assignsFully(v.Elem(), generating) && !resetsLocks(v.Elem(), x, sel) || g.maxDepth > 0 && depth >= g.maxDepth {
+				fmt.Fprintf(w, "*%s = *%s\n", sink, source)
+			}
+			g.countAlloc(w)
+
+			// Fields are selected through the pointer, other values are
+			// copied through its dereference.
+			esource, esink := source, sink
+			if _, ok := v.Elem().Underlying().(*types.Struct); !ok {
+				esource, esink = "(*"+source+")", "(*"+sink+")"
+			}
+
+			g.walkType(esource, esink, x, v.Elem(), w, sel, sels, generating, depth)
+		}
+
+		if g.nilPtrs {
+			fmt.Fprintf(w, "} else {\n%s = %s\n", sink, g.alloc(v.Elem(), x))
+			g.countAlloc(w)
+		}
+
+		fmt.Fprintf(w, "}\n")
+	case *types.Chan:
+		if g.chans == ShareChannels || g.chans == ShareSignalChannels && isSignal(v) {
+			// The channel is shared, as already assigned by the parent.
+			break
+		}
+
+		kind := g.getElemType(v.Elem(), x)
+
+		fmt.Fprintf(w, `if %s != nil {
+	%s = make(chan %s, cap(%s))
+`, source, sink, kind, source)
+		g.countAlloc(w)
+
+		if g.chanBufs && v.Dir() == types.SendRecv {
+			// Each buffered element is received and sent back, so the
+			// source keeps its elements, in order.
+			fmt.Fprintf(w, `for n := len(%s); n > 0; n-- {
+	e := <-%s
+	%s <- e
+	%s <- e
+}
+`, source, source, source, sink)
+		} else if g.chanBufs {
+			g.warnf("WARNING: buffered elements of the directional channel %s are not copied", sel)
+		}
+
+		fmt.Fprintf(w, "}\n")
+	case *types.Interface:
+		if g.errs != ShareErrors && types.Identical(m, errorType) {
+			g.copyError(source, sink, w)
+			break
+		}
+
+		// The dynamic type of an interface value is unknown, so unless
+		// it can copy itself, or is one of the generated types, it is
+		// shared with the source.
+		if call, ok := g.interfaceCopy(v, m, x); ok {
+			if name, ok := g.interfaceHelper(m, call, x); ok {
+				fmt.Fprintf(w, "%s = %s(%s)\n", sink, name, source)
+			} else {
+				fmt.Fprintf(w, "if %s != nil {\n%s = %s.%s\n}\n", source, sink, source, call)
+			}
+		} else if g.ifaces == SwitchInterfaces {
+			g.switchInterface(source, sink, x, m, w, generating, depth)
+		} else if len(sel) > 0 && !strings.HasPrefix(sel[len(sel)-1], "[") {
+			fmt.Fprintf(w, "// WARNING: interface field %s copied shallowly\n", sel)
+		}
+	case *types.Signature:
+		// Functions can't be copied, so the function value, as already
+		// assigned by the parent, is shared with the source.
+		if len(sel) > 0 && !strings.HasPrefix(sel[len(sel)-1], "[") {
+			fmt.Fprintf(w, "// func field %s: shared reference\n", sel)
+		}
+	case *types.Map:
+		kkind := g.getElemType(v.Key(), x)
+		vkind := g.getElemType(v.Elem(), x)
+
+		key, val := "k", "v"
+
+		if depth > 1 {
+			key += strconv.Itoa(depth)
+			val += strconv.Itoa(depth)
+		}
+		key, val = g.localName(key), g.localName(val)
+
+		esel := append(sel, "[k]")
+
+		var skipKey, skipValue bool
+		if sels.skips.ContainsPath(esel) || sels.excludes(esel) {
+			g.tracef(depth, esel, v.Elem(), "skip")
+			skipKey, skipValue = true, true
+		}
+
+		// Pointer keys identify their entry by the pointer, which a copy of
+		// all keys keeps.
+		_, pointerKey := v.Key().Underlying().(*types.Pointer)
+		if !sels.keys.ContainsPath(esel) && (!g.allKeys || pointerKey) {
+			skipKey = true
+		} else if hasPointers(v.Key()) {
+			g.warnf("WARNING: deep copying key of %s with pointers changes its identity in the map", esel)
+		}
+
+		if reused != nil {
+			prev := reusedName(sink)
+			*reused = append(*reused, sink)
+			fmt.Fprintf(w, `if %s != nil {
+	if %s == nil {
+		%s = make(map[%s]%s, len(%s))
+`, source, prev, sink, kkind, vkind, source)
+			g.countAlloc(w)
+			fmt.Fprintf(w, `} else {
+	clear(%s)
+	%s = %s
+}
+`, prev, sink, prev)
+		} else {
+			fmt.Fprintf(w, `if %s != nil {
+	%s = make(map[%s]%s, len(%s))
+`, source, sink, kkind, vkind, source)
+			g.countAlloc(w)
+		}
+		fmt.Fprintf(w, "for %s, %s := range %s {\n", key, val, source)
+
+		ksink, vsink := key, val
+
+		var b bytes.Buffer
+
+		// A key copied with a user method may not be equal to its source
+		// anymore, and distinct keys could collapse into one entry.
+		var guardKeys bool
+
+		if !skipKey {
+			copyKSink := selToIdent(sink) + "_" + key
+			g.walkType(key, copyKSink, x, v.Key(), &b, make(path, 0, 8), sels, generating, depth)
+
+			if b.Len() > 0 {
+				ksink = copyKSink
+				fmt.Fprintf(w, "var %s %s = %s\n", ksink, kkind, key)
+				b.WriteTo(w)
+
+				if k, ok := v.Key().(methoder); ok {
+					guardKeys, _ = g.hasDeepCopy(k, nil)
+				}
+			}
+		}
+
+		b.Reset()
+
+		if !skipValue {
+			copyVSink := selToIdent(sink) + "_" + val
+			g.walkType(val, copyVSink, x, v.Elem(), &b, make(path, 0, 8), sels.rebased(esel), generating, depth)
+
+			if b.Len() > 0 {
+				vsink = copyVSink
+				fmt.Fprintf(w, "var %s %s = %s\n", vsink, vkind, val)
+				b.WriteTo(w)
+			}
+		}
+
+		fmt.Fprintf(w, "%s[%s] = %s", sink, ksink, vsink)
+
+		fmt.Fprintf(w, "}\n")
+
+		if guardKeys {
+			g.warnf("WARNING: deep copying key of %s with its method can collapse distinct keys into one entry, which fails the copy", esel)
+			if g.fallible {
+				fmtName := g.imports.add("fmt", "fmt", func(name string) bool {
+					return g.scope != nil && g.scope.Lookup(name) != nil
+				})
+				fmt.Fprintf(w, `if len(%s) != len(%s) {
+	err := %s.Errorf("deep copy of %s keys collapsed distinct entries")
+	%s
+}
+`, sink, source, fmtName, esel, g.errReturn)
+			} else {
+				fmt.Fprintf(w, `if len(%s) != len(%s) {
+	panic("deep copy of %s keys collapsed distinct entries")
+}
+`, sink, source, esel)
+			}
+		}
+
+		fmt.Fprintf(w, "}\n")
+	}
+}
+
+// errorType is the predeclared error interface.
+var errorType = types.Universe.Lookup("error").Type()
+
+// copyError copies the error source into sink by the error policy.
+func (g Generator) copyError(source, sink string, w io.Writer) {
+	switch g.errs {
+	case WrapErrors:
+		fmtName := g.imports.add("fmt", "fmt", func(name string) bool {
+			return g.scope != nil && g.scope.Lookup(name) != nil
+		})
+		fmt.Fprintf(w, "if %s != nil {\n%s = %s.Errorf(\"%%w\", %s)\n}\n", source, sink, fmtName, source)
+	case SkipErrors:
+		fmt.Fprintf(w, "%s = nil\n", sink)
+	}
+}
+
+// interfaceCopy returns the call of the method of iface copying its dynamic
+// value, if it declares one, converted to t.
+func (g Generator) interfaceCopy(iface *types.Interface, t types.Type, x string) (string, bool) {
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if m.Name() != g.methodName {
+			continue
+		}
+
+		sig := m.Type().(*types.Signature)
+		if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+			return "", false
+		}
+
+		ret := sig.Results().At(0).Type()
+		switch {
+		case types.AssignableTo(ret, t):
+			return g.methodName + "()", true
+		case types.IsInterface(ret):
+			return g.methodName + "().(" + g.getElemType(t, x) + ")", true
+		}
+	}
+
+	return "", false
+}
+
+// interfaceHelper returns the name of the function copying values of the
+// interface type t of the package with call, generating it once.
+func (g Generator) interfaceHelper(t types.Type, call, x string) (string, bool) {
+	if !g.ifaceFuncs || g.helpers == nil {
+		return "", false
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Name() != x || named.TypeArgs().Len() > 0 {
+		return "", false
+	}
+
+	method := []rune(g.methodName)
+	method[0] = unicode.ToLower(method[0])
+	name := g.localName(string(method) + named.Obj().Name())
+	if _, ok := g.helpers[name]; ok {
+		return name, true
+	}
+
+	kind := named.Obj().Name()
+	v := g.localName("v")
+	g.helpers[name] = decl{[]byte(fmt.Sprintf(`// %s generates a deep copy of %s, unless nil
+func %s(%s %s) %s {
+	if %s == nil {
+		return nil
+	}
+	return %s.%s
+}`, name, kind, name, v, kind, kind, v, v, call)), g.imports.scoped()}
+
+	return name, true
+}
+
+// switchInterface copies an interface value holding one of the generated
+// types, or a pointer to one, with the generated method. Generic types are
+// left out, as they cannot be switched over without their type arguments.
+func (g Generator) switchInterface(source, sink, x string, iface types.Type, w io.Writer, generating []object, depth int) {
+	var cases bytes.Buffer
+
+	tv := "t"
+	if depth > 1 {
+		tv += strconv.Itoa(depth)
+	}
+
+	for _, obj := range generating {
+		if g.retyped(obj) {
+			continue
+		}
+		if named, ok := obj.(*types.Named); ok && named.TypeParams().Len() > 0 {
+			continue
+		}
+
+		kind := g.getElemType(obj, x)
+
+		if types.AssignableTo(obj, iface) {
+			if g.isPtrRecv {
+				fmt.Fprintf(&cases, "case %s:\n%s = *%s\n", kind, sink, g.copyCall(tv, obj, false))
+			} else {
+				fmt.Fprintf(&cases, "case %s:\n%s = %s\n", kind, sink, g.copyCall(tv, obj, false))
+			}
+		}
+
+		if types.AssignableTo(types.NewPointer(obj), iface) {
+			if g.isPtrRecv {
+				fmt.Fprintf(&cases, "case *%s:\nif %s != nil {\n%s = %s\n}\n", kind, tv, sink, g.copyCall(tv, obj, true))
+			} else {
+				fmt.Fprintf(&cases, `case *%s:
+	if %s != nil {
+		retV := %s
+		%s = &retV
+	}
+`, kind, tv, g.copyCall(tv, obj, true), sink)
+			}
+		}
+	}
+
+	if cases.Len() == 0 {
+		return
+	}
+
+	// A nil value is already shared, there is no need to switch over it.
+	fmt.Fprintf(w, "if %s != nil {\nswitch %s := %s.(type) {\n", source, tv, source)
+	cases.WriteTo(w)
+	fmt.Fprintf(w, "}\n}\n")
+}
+
+// TagKey is the key of the struct tag controlling the copy of a field in the
+// source, e.g. `deepcopy:"shallow"`, or `deepcopy:"skip"`, to copy the field
+// shallowly, sharing its references with the source.
+const TagKey = "deepcopy"
+
+func (g Generator) skipsTag(tag reflect.StructTag) bool {
+	for _, option := range strings.Split(tag.Get(TagKey), ",") {
+		if option == "skip" || option == "shallow" {
+			return true
+		}
+	}
+
+	for _, ts := range g.tagSkips {
+		if value, ok := tag.Lookup(ts.key); ok && ts.match(value) {
+			return true
+		}
+	}
+
+	return false
+}
 
-	fmt.Fprintf(&file, "// Code generated by deep-copy %s; DO NOT EDIT.\n\npackage %s\n\n", strings.Join(os.Args[1:], " "), p.Name)
+func (g Generator) hasDeepCopy(v methoder, generating []object) (hasMethod, isPointer bool) {
+	for _, t := range generating {
+		if instanceOf(v, t) && !g.retyped(t) {
+			return true, g.isPtrRecv
+		}
+	}
 
-	for _, tag := range g.buildTags {
-		fmt.Fprintf(&file, "//go:build %s\n// +build %s\n", tag, tag)
+	if hasMethod, isPointer = copyMethod(v, g.methodFor(v)); hasMethod {
+		return hasMethod, isPointer
 	}
 
-	if len(g.imports) > 0 {
-		file.WriteString("import (\n")
-		for name, path := range g.imports {
-			if strings.HasSuffix(path, name) {
-				fmt.Fprintf(&file, "%q\n", path)
-			} else {
-				fmt.Fprintf(&file, "%s %q\n", name, path)
-			}
+	if g.fallible {
+		if hasMethod, isPointer = fallibleCopyMethod(v, g.methodFor(v)+"E"); hasMethod {
+			return hasMethod, isPointer
 		}
-		file.WriteString(")\n")
 	}
 
-	for _, fn := range g.fns {
-		file.Write(fn)
-		file.WriteString("\n\n")
+	if g.forwardRef && len(generating) > 0 && g.isCompanion(v, generating[0].Obj().Pkg()) {
+		return true, g.isPtrRecv
 	}
 
-	b, err := format.Source(file.Bytes())
-	if err != nil {
-		return fmt.Errorf("error formatting source: %w\nsource:\n%s", err, file.String())
-	}
+	return false, false
+}
 
-	_, err = w.Write(b)
-	return err
+// retyped reports whether the generated method of t returns another type.
+func (g Generator) retyped(t object) bool {
+	_, ok := g.results[t.Obj().Name()]
+	return ok
 }
 
-func (g Generator) walkType(source, sink, x string, m types.Type, w io.Writer, skips skips, generating []object, depth int) {
-	initial := depth == 0
-	if m == nil {
-		return
+// instanceOf reports whether v is t, or an instance of the generic type t,
+// e.g. Box[int] of Box[T].
+func instanceOf(v types.Type, t object) bool {
+	if types.Identical(v, t) {
+		return true
 	}
 
-	if g.maxDepth > 0 {
-		if depth >= g.maxDepth {
-			p := strings.Split(sink, ".")
-			stoppedAt := strings.TrimSuffix(fmt.Sprintf("%s.%s", generating[0], strings.Join(p[1:len(p)-1], ".")), ".")
-			log.Printf("WARNING: reached max depth %d. stop recursion at %s", depth, stoppedAt)
-			return
+	n, ok := v.(*types.Named)
+	return ok && n.Origin() != n && types.Identical(n.Origin(), t)
+}
+
+// isGenerated reports whether values of v are copied by a generated method,
+// rather than by a method of their own.
+func (g Generator) isGenerated(v methoder, generating []object) bool {
+	for _, t := range generating {
+		if instanceOf(v, t) && !g.retyped(t) {
+			return true
 		}
 	}
 
-	var needExported bool
-	switch v := m.(type) {
-	case *types.Named:
-		if v.Obj().Pkg() != nil && v.Obj().Pkg().Name() != x {
-			needExported = true
-		}
+	if hasMethod, _ := copyMethod(v, g.methodFor(v)); hasMethod {
+		return false
 	}
 
-	if v, ok := m.(methoder); ok && !initial && g.reuseDeepCopy(source, sink, v, false, generating, w) {
-		return
+	return g.forwardRef && len(generating) > 0 && g.isCompanion(v, generating[0].Obj().Pkg())
+}
+
+// copyMethod reports whether v has a method with the given name returning a
+// copy of it, and whether the copy is a pointer.
+func copyMethod(v methoder, name string) (hasMethod, isPointer bool) {
+	return findCopyMethod(v, name, false)
+}
+
+// fallibleCopyMethod reports whether v has a method with the given name
+// returning a copy of it and an error, and whether the copy is a pointer.
+func fallibleCopyMethod(v methoder, name string) (hasMethod, isPointer bool) {
+	return findCopyMethod(v, name, true)
+}
+
+func findCopyMethod(v methoder, name string, fallible bool) (hasMethod, isPointer bool) {
+	results := 1
+	if fallible {
+		results = 2
 	}
 
-	depth++
-	under := m.Underlying()
-	switch v := under.(type) {
-	case *types.Struct:
-		for i := 0; i < v.NumFields(); i++ {
-			field := v.Field(i)
-			if needExported && !field.Exported() {
-				continue
-			}
-			fname := field.Name()
-			sel := sink + "." + fname
-			sel = sel[strings.Index(sel, ".")+1:]
-			if _, ok := skips[sel]; ok {
-				continue
-			}
-			g.walkType(source+"."+fname, sink+"."+fname, x, field.Type(), w, skips, generating, depth)
+	for i := 0; i < v.NumMethods(); i++ {
+		m := v.Method(i)
+		if m.Name() != name {
+			continue
 		}
-	case *types.Slice:
-		kind := g.getElemType(v.Elem(), x)
 
-		idx := "i"
-		if depth > 1 {
-			idx += strconv.Itoa(depth)
+		sig, ok := m.Type().(*types.Signature)
+		if !ok {
+			continue
 		}
 
-		// sel is only used for skips
-		sel := "[i]"
-		sel = sel[strings.Index(sel, ".")+1:]
-		if !initial {
-			sel = sink + sel
+		if sig.Params().Len() != 0 || sig.Results().Len() != results {
+			continue
 		}
-
-		var skipSlice bool
-		if skips.Contains(sel) {
-			skipSlice = true
+		if fallible && !types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type()) {
+			continue
 		}
 
-		fmt.Fprintf(w, `if %s != nil {
-	%s = make([]%s, len(%s))
-`, source, sink, kind, source)
-
-		fmt.Fprintf(w, `copy(%s, %s)
-`, sink, source)
-
-		var b bytes.Buffer
+		ret := sig.Results().At(0)
+		retType, retPointer := reducePointer(ret.Type())
+		sigType, _ := reducePointer(sig.Recv().Type())
 
-		if !skipSlice {
-			baseSel := "[" + idx + "]"
-			g.walkType(source+baseSel, sink+baseSel, x, v.Elem(), &b, skips, generating, depth)
+		if !types.Identical(retType, sigType) {
+			return false, false
 		}
 
-		if b.Len() > 0 {
-			fmt.Fprintf(w, `    for %s := range %s {
-`, idx, source)
+		return true, retPointer
+	}
 
-			b.WriteTo(w)
+	return false, false
+}
 
-			fmt.Fprintf(w, "}\n")
+// assignsFully reports whether the copy of a value of t is assigned to its
+// sink as a whole, rather than replacing parts of a previous copy.
+func (g Generator) assignsFully(t types.Type, generating []object) bool {
+	if v, ok := t.(methoder); ok && !types.IsInterface(t) {
+		if hasMethod, _ := g.hasDeepCopy(v, generating); hasMethod {
+			return true
 		}
+	}
 
-		fmt.Fprintf(w, "}\n")
+	switch v := t.Underlying().(type) {
+	case *types.Slice, *types.Map:
+		return true
+	case *types.Chan:
+		return g.chans == RecreateChannels || g.chans == ShareSignalChannels && !isSignal(v)
 	case *types.Pointer:
-		fmt.Fprintf(w, "if %s != nil {\n", source)
+		_, shared := g.sharedPtrs[types.TypeString(v.Elem(), nil)]
+		return !shared
+	}
 
-		if e, ok := v.Elem().(methoder); !ok || initial || !g.reuseDeepCopy(source, sink, e, true, generating, w) {
-			kind := g.getElemType(v.Elem(), x)
+	return false
+}
 
-			fmt.Fprintf(w, `%s = new(%s)
-	*%s = *%s
-`, sink, kind, sink, source)
+// isSignal reports whether values sent on c carry no data, e.g. chan struct{}.
+func isSignal(c *types.Chan) bool {
+	st, ok := c.Elem().Underlying().(*types.Struct)
+	return ok && st.NumFields() == 0
+}
 
-			g.walkType(source, sink, x, v.Elem(), w, skips, generating, depth)
-		}
+// isLock reports whether t is a lock, e.g. sync.Mutex, or another sync
+// primitive, e.g. sync.WaitGroup, which must not be copied by value once used.
+func isLock(t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Pointer); ok {
+		return false
+	}
 
-		fmt.Fprintf(w, "}\n")
-	case *types.Chan:
-		kind := g.getElemType(v.Elem(), x)
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "sync" {
+		_, ok := named.Underlying().(*types.Struct)
+		return ok
+	}
 
-		fmt.Fprintf(w, `if %s != nil {
-	%s = make(chan %s, cap(%s))
+	// Types embedding a lock hold it, rather than being one.
+	ms := types.NewMethodSet(types.NewPointer(t))
+	lock, unlock := ms.Lookup(nil, "Lock"), ms.Lookup(nil, "Unlock")
+	return lock != nil && unlock != nil && len(lock.Index()) == 1 && len(unlock.Index()) == 1
 }
-`, source, sink, kind, source)
-	case *types.Map:
-		kkind := g.getElemType(v.Key(), x)
-		vkind := g.getElemType(v.Elem(), x)
 
-		key, val := "k", "v"
+// hasLock reports whether t is or holds a lock by value.
+func hasLock(t types.Type) bool {
+	if isLock(t) {
+		return true
+	}
 
-		if depth > 1 {
-			key += strconv.Itoa(depth)
-			val += strconv.Itoa(depth)
+	switch v := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < v.NumFields(); i++ {
+			if hasLock(v.Field(i).Type()) {
+				return true
+			}
 		}
+	case *types.Array:
+		return hasLock(v.Elem())
+	}
 
-		// Sel is only used for skips
-		sel := "[k]"
-		if !initial {
-			sel = sink + sel
-		}
-		sel = sel[strings.Index(sel, ".")+1:]
+	return false
+}
 
-		var skipKey, skipValue bool
-		if skips.Contains(sel) {
-			skipKey, skipValue = true, true
-		}
+// accessible reports whether field can be selected in the package x. The
+// fields of a struct type, named or not, belong to the package declaring it,
+// which may differ from the package of an enclosing type.
+func accessible(field *types.Var, x string) bool {
+	return field.Exported() || field.Pkg() == nil || field.Pkg().Name() == x
+}
 
-		fmt.Fprintf(w, `if %s != nil {
-	%s = make(map[%s]%s, len(%s))
-	for %s, %s := range %s {
-`, source, sink, kkind, vkind, source, key, val, source)
+// hidesReferences reports whether t is a struct type with a field holding
+// references which can't be selected in the package x, and so can't be
+// copied.
+func hidesReferences(t types.Type, x string) bool {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
 
-		ksink, vsink := key, val
+	for i := 0; i < st.NumFields(); i++ {
+		if field := st.Field(i); !accessible(field, x) && hasPointers(field.Type()) {
+			return true
+		}
+	}
 
-		var b bytes.Buffer
+	return false
+}
 
-		if !skipKey {
-			copyKSink := selToIdent(sink) + "_" + key
-			g.walkType(key, copyKSink, x, v.Key(), &b, skips, generating, depth)
+// holdsLocks reports whether t is a struct type of the package x holding a
+// lock, of which the copy can assign every field but the locks.
+func holdsLocks(t types.Type, x string) bool {
+	if _, ok := t.Underlying().(*types.Struct); !ok || isLock(t) || !hasLock(t) {
+		return false
+	}
 
-			if b.Len() > 0 {
-				ksink = copyKSink
-				fmt.Fprintf(w, "var %s %s\n", ksink, kkind)
-				b.WriteTo(w)
-			}
-		}
+	named, ok := t.(*types.Named)
+	return !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Name() == x
+}
 
-		b.Reset()
+// resetsLocks reports whether the copy of the field or pointed value at sel,
+// of type t, assigns each field but the locks, instead of being copied as a
+// whole first. Elements of slices, arrays and maps are copied as a whole.
+func resetsLocks(t types.Type, x string, sel path) bool {
+	return holdsLocks(t, x) && len(sel) > 0 && !strings.HasPrefix(sel[len(sel)-1], "[")
+}
 
-		if !skipValue {
-			copyVSink := selToIdent(sink) + "_" + val
-			g.walkType(val, copyVSink, x, v.Elem(), &b, skips, generating, depth)
+// startsEmpty reports whether the copy of the generated type obj starts
+// from its zero value, with each field assigned explicitly.
+func (g Generator) startsEmpty(obj types.Type, x string) bool {
+	if _, ok := obj.Underlying().(*types.Struct); !ok {
+		return false
+	}
 
-			if b.Len() > 0 {
-				vsink = copyVSink
-				fmt.Fprintf(w, "var %s %s\n", vsink, vkind)
-				b.WriteTo(w)
-			}
-		}
+	return g.explicit || holdsLocks(obj, x)
+}
 
-		fmt.Fprintf(w, "%s[%s] = %s", sink, ksink, vsink)
+// isCompanion reports whether t is a non-generic struct type of pkg, without
+// a method named methodName.
+func (g Generator) isCompanion(t types.Type, pkg *types.Package) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() != pkg || named.TypeParams().Len() > 0 || named.TypeArgs().Len() > 0 {
+		return false
+	}
 
-		fmt.Fprintf(w, "}\n}\n")
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return false
 	}
-}
 
-func (g Generator) hasDeepCopy(v methoder, generating []object) (hasMethod, isPointer bool) {
-	for _, t := range generating {
-		if types.Identical(v, t) {
-			return true, g.isPtrRecv
+	for i := 0; i < named.NumMethods(); i++ {
+		if named.Method(i).Name() == g.methodFor(named) {
+			return false
 		}
 	}
 
-	for i := 0; i < v.NumMethods(); i++ {
-		m := v.Method(i)
-		if m.Name() != g.methodName {
-			continue
-		}
+	return true
+}
 
-		sig, ok := m.Type().(*types.Signature)
-		if !ok {
-			continue
-		}
+// addCompanions appends the companion types reachable from objs, and from
+// the appended ones in turn.
+func (g Generator) addCompanions(objs []object) []object {
+	if len(objs) == 0 {
+		return objs
+	}
 
-		if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
-			continue
-		}
+	pkg := objs[0].Obj().Pkg()
+	seen := make(map[types.Type]bool, len(objs))
+	for _, obj := range objs {
+		seen[obj] = true
+	}
 
-		ret := sig.Results().At(0)
-		retType, retPointer := reducePointer(ret.Type())
-		sigType, _ := reducePointer(sig.Recv().Type())
+	for i := 0; i < len(objs); i++ {
+		visitNamed(objs[i].Underlying(), func(n *types.Named) {
+			if !seen[n] && g.isCompanion(n, pkg) {
+				seen[n] = true
+				objs = append(objs, n)
+			}
+		})
+	}
 
-		if !types.Identical(retType, sigType) {
-			return false, false
+	return objs
+}
+
+// visitNamed calls visit for each named type that values of t hold, without
+// descending into the named types themselves.
+func visitNamed(t types.Type, visit func(*types.Named)) {
+	switch v := t.(type) {
+	case *types.Named:
+		visit(v)
+	case *types.Struct:
+		for i := 0; i < v.NumFields(); i++ {
+			visitNamed(v.Field(i).Type(), visit)
 		}
+	case *types.Pointer:
+		visitNamed(v.Elem(), visit)
+	case *types.Slice:
+		visitNamed(v.Elem(), visit)
+	case *types.Array:
+		visitNamed(v.Elem(), visit)
+	case *types.Map:
+		visitNamed(v.Key(), visit)
+		visitNamed(v.Elem(), visit)
+	}
+}
 
-		return true, retPointer
+// constrainedCopy reports whether the constraint of the type parameter
+// requires a method copying its values, e.g. DeepCopy() T.
+func (g Generator) constrainedCopy(v *types.TypeParam) bool {
+	obj, _, _ := types.LookupFieldOrMethod(v, false, v.Obj().Pkg(), g.methodName)
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
 	}
 
-	return false, false
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 && types.Identical(sig.Results().At(0).Type(), v)
 }
 
-func (g Generator) reuseDeepCopy(source, sink string, v methoder, pointer bool, generating []object, w io.Writer) bool {
+func (g Generator) reuseDeepCopy(source, sink, x string, v methoder, pointer bool, generating []object, w io.Writer) bool {
+	name := g.methodFor(v)
 	hasMethod, isPointer := g.hasDeepCopy(v, generating)
 
+	// Types holding a lock can not be copied field by field without copying
+	// the lock, nor can types of other packages holding references in their
+	// unexported fields be copied fully, so their own Clone method is reused
+	// as well.
+	if !hasMethod && (hasLock(v) || hidesReferences(v, x)) {
+		name = "Clone"
+		hasMethod, isPointer = copyMethod(v, name)
+	}
+
+	// In fallible mode, the generated methods return an error as well, and
+	// so do the fallible methods of other types.
+	var fallible bool
+	if g.fallible && hasMethod {
+		if g.isGenerated(v, generating) {
+			fallible = true
+		} else if ok, _ := copyMethod(v, name); !ok {
+			name += "E"
+			fallible = true
+		}
+	}
+
 	if hasMethod {
-		if pointer == isPointer {
-			fmt.Fprintf(w, "%s = %s.%s()\n", sink, source, g.methodName)
+		call := source + "." + name + "()"
+		if g.standalone && g.isGenerated(v, generating) {
+			call = g.copyCall(source, v, pointer)
+		}
+
+		if fallible {
+			retV := "retV"
+			if pointer && !isPointer {
+				retV = "&retV"
+			} else if !pointer && isPointer {
+				retV = "*retV"
+			}
+			copied := fmt.Sprintf(`retV, err := %s
+	if err != nil {
+		%s
+	}
+	%s = %s
+`, call, g.errReturn, sink, retV)
+			if pointer {
+				fmt.Fprint(w, copied)
+			} else {
+				fmt.Fprintf(w, "{\n%s}\n", copied)
+			}
+		} else if pointer == isPointer {
+			fmt.Fprintf(w, "%s = %s\n", sink, call)
 		} else if pointer {
-			fmt.Fprintf(w, `retV := %s.%s()
+			fmt.Fprintf(w, `retV := %s
 	%s = &retV
-`, source, g.methodName, sink)
+`, call, sink)
 		} else {
 			fmt.Fprintf(w, `{
-	retV := %s.%s()
+	retV := %s
 	%s = *retV
 }
-`, source, g.methodName, sink)
+`, call, sink)
 		}
 	}
 
@@ -432,8 +3351,11 @@ func (g Generator) reuseDeepCopy(source, sink string, v methoder, pointer bool,
 }
 
 func locateType(kind string, p *packages.Package) (object, error) {
+	var local []object
 	for _, t := range p.TypesInfo.Defs {
-		if t == nil {
+		// Only declarations of the type itself are considered, and not e.g.
+		// fields of an instance of it, such as Box[int] of Box[T].
+		if _, ok := t.(*types.TypeName); !ok {
 			continue
 		}
 		m := exprFilter(t.Type(), kind, p.Name)
@@ -441,10 +3363,31 @@ func locateType(kind string, p *packages.Package) (object, error) {
 			continue
 		}
 
-		return m, nil
+		// A package-level type is unique, and preferred over types of the
+		// same name declared in functions.
+		if t.Parent() == p.Types.Scope() {
+			return m, nil
+		}
+		local = append(local, m)
+	}
+
+	switch len(local) {
+	case 0:
+		return nil, errors.New("type not found")
+	case 1:
+		return local[0], nil
+	}
+
+	// The definitions are a map, so the candidates are sorted for a stable
+	// error.
+	sort.Slice(local, func(i, j int) bool { return local[i].Obj().Pos() < local[j].Obj().Pos() })
+	positions := make([]string, len(local))
+	for i, m := range local {
+		pos := p.Fset.Position(m.Obj().Pos())
+		positions[i] = fmt.Sprintf("%s:%d:%d", filepath.Base(pos.Filename), pos.Line, pos.Column)
 	}
 
-	return nil, errors.New("type not found")
+	return nil, fmt.Errorf("ambiguous type, declared in %d functions: %s", len(local), strings.Join(positions, ", "))
 }
 
 func reducePointer(typ types.Type) (types.Type, bool) {
@@ -479,27 +3422,136 @@ func exprFilter(t types.Type, sel string, x string) object {
 	return m
 }
 
-var importSanitizerRE = regexp.MustCompile(`\W`)
+// alloc returns the expression allocating a value of type t, the allocator
+// given for it, if any.
+func (g Generator) alloc(t types.Type, x string) string {
+	if expr, ok := g.allocators[types.TypeString(t, nil)]; ok {
+		return expr
+	}
+
+	return "new(" + g.getElemType(t, x) + ")"
+}
+
+// anyRE matches the predeclared any in a type string, but not a qualified
+// identifier, e.g. pkg.any.
+var anyRE = regexp.MustCompile(`(^|[^\w.])any\b`)
+
+// localName returns name, suffixed with underscores if a package-level
+// declaration would be shadowed by it.
+func (g Generator) localName(name string) string {
+	for g.scope != nil && g.scope.Lookup(name) != nil {
+		name += "_"
+	}
+
+	return name
+}
 
 func (g Generator) getElemType(t types.Type, x string) string {
-	kind := types.TypeString(t, func(p *types.Package) string {
-		name := p.Name()
-		if name != x {
-			if path, ok := g.imports[name]; ok && path != p.Path() {
-				name = importSanitizerRE.ReplaceAllString(p.Path(), "_")
+	kind := types.TypeString(t, g.qualifier(x))
+
+	// Both any and interface{} denote the empty interface, which is rendered
+	// consistently, whichever is used in the source.
+	if g.ifaceLit {
+		kind = anyRE.ReplaceAllString(kind, "${1}interface{}")
+	} else {
+		kind = strings.ReplaceAll(kind, "interface{}", "any")
+	}
+
+	return kind
+}
+
+// hasPointers reports whether values of t hold a reference, either directly
+// or through one of their fields or elements.
+func hasPointers(t types.Type) bool {
+	switch v := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < v.NumFields(); i++ {
+			if hasPointers(v.Field(i).Type()) {
+				return true
 			}
+		}
+		return false
+	case *types.Array:
+		return hasPointers(v.Elem())
+	case *types.Basic:
+		return v.Kind() == types.UnsafePointer
+	default:
+		return true
+	}
+}
 
-			g.imports[name] = p.Path()
-			return name
+// hasCoreType reports whether the types in the type set of tp share a single
+// underlying type, e.g. ~[]byte, unlike ~string | ~[]byte. Constraints
+// without type terms, e.g. any, are assumed to have one.
+func hasCoreType(tp *types.TypeParam) bool {
+	iface, ok := tp.Constraint().Underlying().(*types.Interface)
+	if !ok {
+		return true
+	}
+
+	var core types.Type
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		var terms []types.Type
+		switch e := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < e.Len(); j++ {
+				terms = append(terms, e.Term(j).Type())
+			}
+		default:
+			if !types.IsInterface(e) {
+				terms = append(terms, e)
+			}
 		}
-		return ""
-	})
 
-	return kind
+		for _, t := range terms {
+			if core == nil {
+				core = t.Underlying()
+			} else if !types.Identical(core, t.Underlying()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// zeroValue returns an expression of the zero value of t.
+func (g Generator) zeroValue(t types.Type, x string) string {
+	if _, ok := t.(*types.TypeParam); ok {
+		return "*new(" + g.getElemType(t, x) + ")"
+	}
+
+	switch v := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case v.Info()&types.IsBoolean != 0:
+			return "false"
+		case v.Info()&types.IsString != 0:
+			return `""`
+		case v.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return g.getElemType(t, x) + "{}"
+	}
+
+	return "nil"
+}
+
+// onlyComments reports whether the code holds no statement, but comments.
+func onlyComments(code []byte) bool {
+	for _, line := range bytes.Split(code, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && !bytes.HasPrefix(line, []byte("//")) {
+			return false
+		}
+	}
+
+	return true
 }
 
 func selToIdent(sel string) string {
-	sel = strings.ReplaceAll(sel, "]", "")
+	sel = strings.NewReplacer("]", "", "(", "", ")", "", "*", "").Replace(sel)
 
 	return strings.Map(func(r rune) rune {
 		switch r {
@@ -1,9 +1,15 @@
 package deepcopy
 
 import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
 )
 
 func TestNewGenerator(t *testing.T) {
@@ -11,8 +17,10 @@ func TestNewGenerator(t *testing.T) {
 		g := NewGenerator()
 		assert.Equal(t, Generator{
 			methodName: "DeepCopy",
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 
@@ -21,8 +29,10 @@ func TestNewGenerator(t *testing.T) {
 		assert.Equal(t, Generator{
 			methodName: "DeepCopy",
 			isPtrRecv:  true,
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 
@@ -30,8 +40,10 @@ func TestNewGenerator(t *testing.T) {
 		g := NewGenerator(WithMethodName("FuncDeepCopy"))
 		assert.Equal(t, Generator{
 			methodName: "FuncDeepCopy",
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 
@@ -40,8 +52,10 @@ func TestNewGenerator(t *testing.T) {
 		assert.Equal(t, Generator{
 			methodName: "DeepCopy",
 			maxDepth:   15,
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 
@@ -54,8 +68,10 @@ func TestNewGenerator(t *testing.T) {
 		assert.Equal(t, Generator{
 			methodName: "DeepCopy",
 			skipLists:  sl,
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 
@@ -65,8 +81,32 @@ func TestNewGenerator(t *testing.T) {
 		assert.Equal(t, Generator{
 			methodName: "DeepCopy",
 			buildTags:  bts,
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
+		}, g)
+	})
+
+	t.Run("WithSharedPointers", func(t *testing.T) {
+		g := NewGenerator(WithSharedPointers("example.com/pool.Conn"))
+		assert.Equal(t, Generator{
+			methodName: "DeepCopy",
+			sharedPtrs: map[string]struct{}{"time.Location": {}, "example.com/pool.Conn": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
+		}, g)
+	})
+
+	t.Run("WithValueTypes", func(t *testing.T) {
+		g := NewGenerator(WithValueTypes("net/netip.Addr"))
+		assert.Equal(t, Generator{
+			methodName: "DeepCopy",
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}, "net/netip.Addr": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 
@@ -78,8 +118,156 @@ func TestNewGenerator(t *testing.T) {
 		assert.Equal(t, Generator{
 			isPtrRecv:  true,
 			methodName: "FuncDeepCopy",
-			imports:    map[string]string{},
-			fns:        [][]byte{},
+			sharedPtrs: map[string]struct{}{"time.Location": {}},
+			valueTypes: map[string]struct{}{"time.Time": {}, "time.Duration": {}},
+			imports:    newImportSet(),
+			fns:        []decl{},
 		}, g)
 	})
 }
+
+func TestCanonicalSelector(t *testing.T) {
+	for sel, want := range map[string]string{
+		"Items":             "Items",
+		"Items[i]":          "Items[]",
+		"Items[].Secret":    "Items[].Secret",
+		"Items[i].Secret":   "Items[].Secret",
+		"Map[k][i].Tags":    "Map[][].Tags",
+		"[i]":               "[]",
+		"Meta.Notes[k].Ref": "Meta.Notes[].Ref",
+	} {
+		assert.Equal(t, want, canonicalSelector(sel), sel)
+	}
+}
+
+func TestGenerateToFile(t *testing.T) {
+	// The fixture is copied, so the file written next to its sources stays
+	// out of the source tree.
+	dir := t.TempDir()
+	src, err := os.ReadFile(filepath.Join("..", "testdata", "tofile", "tofile.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tofile.go"), src, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module tofile\n\ngo 1.21\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Mode: LoadMode, Dir: dir}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pkgs[0]
+
+	g := NewGenerator()
+
+	t.Run("given path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "copy.go")
+		if err := g.GenerateToFile(path, []string{"Config"}, p); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, string(b), "package tofile")
+		assert.Contains(t, string(b), "func (o Config) DeepCopy() Config {")
+	})
+
+	t.Run("next to the sources", func(t *testing.T) {
+		path := filepath.Join(dir, "tofile_deepcopy.go")
+		if err := g.GenerateToFile("", []string{"Config"}, p); err != nil {
+			t.Fatal(err)
+		}
+		_, err := os.Stat(path)
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed generation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "copy.go")
+		assert.Error(t, g.GenerateToFile(path, []string{"Missing"}, p))
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestSelectorsRebased(t *testing.T) {
+	s := selectors{
+		skips: skips{"Slice": {}, "Map[].Secret": {}, "ByName[][].Tags": {}},
+		keys:  skips{"Other[]": {}},
+	}
+
+	got := s.rebased(path{"Map", "[k]"})
+	assert.Equal(t, skips{"Slice": {}, "Map[].Secret": {}, "Secret": {}, "ByName[][].Tags": {}}, got.skips)
+	assert.Equal(t, s.keys, got.keys)
+
+	got = s.rebased(path{"ByName", "[k]"})
+	assert.Equal(t, skips{"Slice": {}, "Map[].Secret": {}, "ByName[][].Tags": {}, "[].Tags": {}}, got.skips)
+}
+
+func TestClearsMaps(t *testing.T) {
+	for goVersion, want := range map[string]bool{
+		"":       false,
+		"1.20":   false,
+		"1.21":   true,
+		"1.21.0": true,
+		"1.23":   true,
+	} {
+		p := &packages.Package{Module: &packages.Module{GoVersion: goVersion}}
+		assert.Equal(t, want, clearsMaps(p), goVersion)
+	}
+	assert.False(t, clearsMaps(&packages.Package{}))
+}
+
+// wideStruct returns a struct type of n slice fields with long names.
+func wideStruct(n int) *types.Named {
+	pkg := types.NewPackage("example.com/wide", "wide")
+	fields := make([]*types.Var, n)
+	for i := range fields {
+		fields[i] = types.NewField(token.NoPos, pkg, fmt.Sprintf("SubscriptionRenewalSetting%03d", i), types.NewSlice(types.Typ[types.Int]), false)
+	}
+	obj := types.NewTypeName(token.NoPos, pkg, "Wide", nil)
+
+	return types.NewNamed(obj, types.NewStruct(fields, nil), nil)
+}
+
+func TestWalkTypeWideStructAllocs(t *testing.T) {
+	g := NewGenerator()
+	p := &packages.Package{Name: "wide"}
+	allocs := func(n int, sels selectors) float64 {
+		named := wideStruct(n)
+		return testing.AllocsPerRun(10, func() {
+			if _, err := g.generateFunc(p, named, sels, []object{named}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	// Matching the selectors of the fields against a skip list doesn't
+	// allocate per field.
+	sels := selectors{skips: skips{"Unrelated": struct{}{}}}
+	base, skipped := allocs(200, selectors{}), allocs(200, sels)
+	assert.LessOrEqual(t, skipped-base, 10.0, "allocations of the skip matching over 200 fields")
+
+	// Nor do the allocations of a field grow with the number of fields, at
+	// about 40 each.
+	perField := (skipped - allocs(100, sels)) / 100
+	assert.LessOrEqual(t, perField, 50.0, "allocations per field")
+}
+
+func BenchmarkWalkTypeWideStruct(b *testing.B) {
+	named := wideStruct(200)
+	g := NewGenerator()
+	p := &packages.Package{Name: "wide"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := g.generateFunc(p, named, selectors{skips: skips{"Unrelated": struct{}{}}}, []object{named})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
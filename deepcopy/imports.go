@@ -0,0 +1,117 @@
+package deepcopy
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+var importSanitizerRE = regexp.MustCompile(`\W`)
+
+// importNames assigns each package imported by the generated file a unique
+// name, once, by which its types are qualified and it is imported.
+type importNames struct {
+	// names are the names of the packages, by path.
+	names map[string]string
+	// paths are the paths of the packages, by name.
+	paths map[string]string
+	// aliased are the paths whose name differs from the package name, which
+	// are imported with an explicit name.
+	aliased map[string]bool
+	// preferred are the names chosen for packages by path, used unless
+	// already in use.
+	preferred map[string]string
+}
+
+// importSet is the set of the packages used by a generated declaration, or
+// by a whole file, named by the importNames of the file.
+type importSet struct {
+	*importNames
+	// uses are the paths of the packages in the set.
+	uses map[string]bool
+}
+
+func newImportSet() *importSet {
+	return &importSet{
+		importNames: &importNames{
+			names:   map[string]string{},
+			paths:   map[string]string{},
+			aliased: map[string]bool{},
+		},
+		uses: map[string]bool{},
+	}
+}
+
+// scoped returns an empty set, naming packages like s.
+func (s *importSet) scoped() *importSet {
+	return &importSet{importNames: s.importNames, uses: map[string]bool{}}
+}
+
+// merge adds the packages of o to s.
+func (s *importSet) merge(o *importSet) {
+	for path := range o.uses {
+		s.uses[path] = true
+	}
+}
+
+// add returns the name of the package with the given path and name, adding
+// it to the set. A package is named by its preferred name, if any. A package
+// whose name is already used, by another package or as reported by taken, is
+// named after its path instead.
+func (s *importSet) add(path, name string, taken func(name string) bool) string {
+	s.uses[path] = true
+
+	if n, ok := s.names[path]; ok {
+		return n
+	}
+
+	alias := name
+	if n, ok := s.preferred[path]; ok {
+		alias = n
+	}
+	if s.inUse(alias, taken) {
+		alias = importSanitizerRE.ReplaceAllString(path, "_")
+		for i := 2; s.inUse(alias, taken); i++ {
+			alias = fmt.Sprintf("%s_%d", importSanitizerRE.ReplaceAllString(path, "_"), i)
+		}
+	}
+
+	s.names[path] = alias
+	s.paths[alias] = path
+	s.aliased[path] = alias != name
+
+	return alias
+}
+
+func (n *importNames) inUse(name string, taken func(name string) bool) bool {
+	if _, ok := n.paths[name]; ok {
+		return true
+	}
+
+	return taken != nil && taken(name)
+}
+
+// writeTo writes the import declaration of the set, sorted by path, so the
+// output is stable across runs.
+func (s *importSet) writeTo(w io.Writer) {
+	if len(s.uses) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(s.uses))
+	for path := range s.uses {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprint(w, "import (\n")
+	for _, path := range paths {
+		if s.aliased[path] {
+			fmt.Fprintf(w, "%s %q\n", s.names[path], path)
+		} else {
+			fmt.Fprintf(w, "%q\n", path)
+		}
+	}
+	fmt.Fprint(w, ")\n")
+}
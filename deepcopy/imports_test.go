@@ -0,0 +1,81 @@
+package deepcopy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportSet(t *testing.T) {
+	t.Run("packages of the same name", func(t *testing.T) {
+		s := newImportSet()
+		assert.Equal(t, "item", s.add("example.com/a/item", "item", nil))
+		assert.Equal(t, "example_com_b_item", s.add("example.com/b/item", "item", nil))
+		assert.Equal(t, "item", s.add("example.com/a/item", "item", nil))
+
+		var buf bytes.Buffer
+		s.writeTo(&buf)
+		assert.Equal(t, `import (
+"example.com/a/item"
+example_com_b_item "example.com/b/item"
+)
+`, buf.String())
+	})
+
+	t.Run("name differing from the path", func(t *testing.T) {
+		s := newImportSet()
+		assert.Equal(t, "yaml", s.add("example.com/yaml.v3", "yaml", nil))
+
+		var buf bytes.Buffer
+		s.writeTo(&buf)
+		assert.Equal(t, "import (\n\"example.com/yaml.v3\"\n)\n", buf.String())
+	})
+
+	t.Run("name taken by the package", func(t *testing.T) {
+		s := newImportSet()
+		taken := func(name string) bool { return name == "errors" }
+		assert.Equal(t, "errors_2", s.add("errors", "errors", taken))
+
+		var buf bytes.Buffer
+		s.writeTo(&buf)
+		assert.Equal(t, "import (\nerrors_2 \"errors\"\n)\n", buf.String())
+	})
+
+	t.Run("preferred names", func(t *testing.T) {
+		s := newImportSet()
+		s.preferred = map[string]string{"example.com/v2/item": "item2", "example.com/v3/item": "item3"}
+		taken := func(name string) bool { return name == "item3" }
+		assert.Equal(t, "item2", s.add("example.com/v2/item", "item", taken))
+		assert.Equal(t, "example_com_v3_item", s.add("example.com/v3/item", "item", taken))
+
+		var buf bytes.Buffer
+		s.writeTo(&buf)
+		assert.Equal(t, `import (
+item2 "example.com/v2/item"
+example_com_v3_item "example.com/v3/item"
+)
+`, buf.String())
+	})
+
+	t.Run("scoped sets", func(t *testing.T) {
+		file := newImportSet()
+		a, b := file.scoped(), file.scoped()
+		assert.Equal(t, "item", a.add("example.com/a/item", "item", nil))
+		assert.Equal(t, "example_com_b_item", b.add("example.com/b/item", "item", nil))
+		assert.Equal(t, "item", b.add("example.com/a/item", "item", nil))
+		assert.Equal(t, map[string]bool{"example.com/a/item": true}, a.uses)
+
+		imports := file.scoped()
+		imports.merge(a)
+		imports.merge(b)
+
+		var buf bytes.Buffer
+		imports.writeTo(&buf)
+		assert.Equal(t, `import (
+"example.com/a/item"
+example_com_b_item "example.com/b/item"
+)
+`, buf.String())
+	})
+}
@@ -0,0 +1,28 @@
+package deepcopy
+
+import (
+	"errors"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadMode is the packages.LoadMode a package given to Generate must be
+// loaded with, at least. The generator relies on the Name, PkgPath, GoFiles,
+// Types and TypesInfo fields of the package, on Fset to reference sources
+// with WithSourceComments, and on Module for the Go version it requires. The
+// syntax trees are not needed.
+const LoadMode = packages.NeedName | packages.NeedFiles | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedImports | packages.NeedModule
+
+// LoadPackage loads the first package matching the patterns, e.g. an import
+// path or a directory, with LoadMode, ready to be given to Generate.
+func LoadPackage(patterns ...string) (*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: LoadMode}, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, errors.New("no package found")
+	}
+
+	return pkgs[0], nil
+}
@@ -0,0 +1,411 @@
+package deepcopy
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TestBuildTag is the build constraint of the generated round-trip tests. The
+// tests are only compiled when it is explicitly enabled, e.g. with
+// `go test -tags deepcopytest`.
+const TestBuildTag = "deepcopytest"
+
+// GenerateTests writes a test file which checks that the generated methods
+// of the given types produce copies equal to their source. The file is
+// guarded by TestBuildTag, so it stays out of a normal `go test` run, along
+// with the build constraint and tags of the generated methods.
+func (g Generator) GenerateTests(w io.Writer, types []string, p *packages.Package) error {
+	g.imports = g.newImports()
+	tags := make([]string, 0, len(g.buildTags)+1)
+	if g.constraint != "" {
+		tags = append(tags, g.constraint)
+	}
+	g.constraint, g.buildTags = TestBuildTag, append(tags, g.buildTags...)
+	g.fns = make([]decl, 0, len(types))
+
+	for i, kind := range types {
+		obj, err := locateType(kind, p)
+		if err != nil {
+			return fmt.Errorf("locating type %q in %q: %v", kind, p.Name, err)
+		}
+
+		imports := g.imports.scoped()
+		imports.add("reflect", "reflect", nil)
+		imports.add("testing", "testing", nil)
+		tg := g
+		tg.imports = imports
+		typ, err := tg.testType(obj, p.Name)
+		if err != nil {
+			return err
+		}
+		g.fns = append(g.fns, decl{g.generateTestFunc(i, obj, typ), imports})
+		if g.assertions {
+			g.fns = append(g.fns, decl{g.generateAssertFunc(i, obj, typ), imports})
+		}
+	}
+
+	if len(types) > 0 {
+		imports := g.imports.scoped()
+		imports.add("reflect", "reflect", nil)
+		g.fns = append(g.fns, decl{g.generateFill(), imports})
+	}
+
+	if g.assertions && len(types) > 0 {
+		imports := g.imports.scoped()
+		imports.add("reflect", "reflect", nil)
+		if len(g.sharedPtrs) > 0 {
+			imports.add("strings", "strings", nil)
+		}
+		g.fns = append(g.fns, decl{g.generateSharedReferences(), imports})
+	}
+
+	err := g.generateFile(w, p)
+	if err != nil {
+		return fmt.Errorf("generating test file content: %v", err)
+	}
+
+	return nil
+}
+
+// testType returns the type, in the package x, the round-trip test of obj
+// copies: obj itself, or, for a generic type, its instance with the core type
+// of each constraint, or any for a constraint without one.
+func (g Generator) testType(obj object, x string) (string, error) {
+	named, ok := obj.(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return types.TypeString(obj, g.qualifier(x)), nil
+	}
+
+	args := make([]types.Type, named.TypeParams().Len())
+	for i := range args {
+		args[i] = types.Universe.Lookup("any").Type()
+
+		iface, ok := named.TypeParams().At(i).Constraint().Underlying().(*types.Interface)
+		if !ok || iface.NumEmbeddeds() != 1 {
+			continue
+		}
+		switch e := iface.EmbeddedType(0).(type) {
+		case *types.Union:
+			if e.Len() == 1 {
+				args[i] = e.Term(0).Type()
+			}
+		default:
+			if !types.IsInterface(e) {
+				args[i] = e
+			}
+		}
+	}
+
+	inst, err := types.Instantiate(nil, named, args, true)
+	if err != nil {
+		return "", fmt.Errorf("instantiating the generic type %s for its round-trip test: %v", named.Obj().Name(), err)
+	}
+
+	return types.TypeString(inst, g.qualifier(x)), nil
+}
+
+// testFillDepth is the depth the source of the round-trip tests is filled
+// to, without a max depth.
+const testFillDepth = 5
+
+// generateTestFunc generates the round-trip test of obj, the i-th of the
+// types, copying a source of type typ filled by deepCopyFill.
+func (g Generator) generateTestFunc(i int, obj object, typ string) []byte {
+	var buf bytes.Buffer
+
+	kind := obj.Obj().Name()
+
+	depth := testFillDepth
+	if g.maxDepth > 0 {
+		depth = g.maxDepth
+	}
+
+	init := fmt.Sprintf("var o %s\n\tdeepCopyFill(reflect.ValueOf(&o).Elem(), \"\", %s, %d)", typ, g.leftUnfilled(i, obj), depth)
+	if g.isPtrRecv {
+		init = fmt.Sprintf("o := new(%s)\n\tdeepCopyFill(reflect.ValueOf(o).Elem(), \"\", %s, %d)", typ, g.leftUnfilled(i, obj), depth)
+	}
+
+	method := g.methodFor(obj)
+	call, name := "o."+method+"()", kind+"."+method+"()"
+	if g.standalone {
+		call, name = g.funcName(obj)+"(o)", g.funcName(obj)+"()"
+	}
+
+	assign := "cp := " + call
+	if g.fallible {
+		assign = "cp, err := " + call + "\n\tif err != nil {\n\t\tt.Fatal(err)\n\t}"
+	}
+
+	// A copy returned as another type is compared converted back.
+	got := "cp"
+	if g.retyped(obj) {
+		got = typ + "(cp)"
+		if g.isPtrRecv {
+			got = "(*" + typ + ")(cp)"
+		}
+	}
+
+	if g.assertions {
+		// The copy is checked by value, like the arguments of the assertion.
+		src := "o"
+		if g.isPtrRecv {
+			src, got = "*o", "*"+got
+		}
+
+		fmt.Fprintf(&buf, `func Test%s%sRoundTrip(t *testing.T) {
+	%s
+	%s
+	%s(t, %s, %s)
+}`, kind, method, init, assign, assertFuncName(obj), src, got)
+
+		return buf.Bytes()
+	}
+
+	fmt.Fprintf(&buf, `func Test%s%sRoundTrip(t *testing.T) {
+	%s
+	%s
+	if !reflect.DeepEqual(o, %s) {
+		t.Errorf("%s = %%v, want %%v", cp, o)
+	}
+}`, kind, method, init, assign, got, name)
+
+	return buf.Bytes()
+}
+
+// leftUnfilled returns the map literal of the selectors of obj, the i-th of
+// the types, which deepCopyFill leaves zero: the reset fields, which the
+// copy doesn't keep, and all but one of the union fields, as the copy
+// checks that exactly one is set.
+func (g Generator) leftUnfilled(i int, obj object) string {
+	var leave []string
+	for sel := range expandPromoted(g.resetLists.Get(i), obj) {
+		leave = append(leave, fmt.Sprintf("%q: true", sel))
+	}
+
+	if st, ok := obj.Underlying().(*types.Struct); ok && len(g.oneOfLists.Get(i)) > 0 {
+		var fields []string
+		for name := range g.oneOfLists.Get(i) {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+
+		// The set field is the first one which can be filled.
+		var set string
+		for _, name := range fields {
+			for j := 0; j < st.NumFields(); j++ {
+				if st.Field(j).Name() != name {
+					continue
+				}
+				switch st.Field(j).Type().Underlying().(type) {
+				case *types.Pointer, *types.Slice, *types.Map:
+					if set == "" {
+						set = name
+					}
+				}
+			}
+		}
+		for _, name := range fields {
+			if name != set {
+				leave = append(leave, fmt.Sprintf("%q: true", name))
+			}
+		}
+	}
+	sort.Strings(leave)
+
+	if len(leave) == 0 {
+		return "nil"
+	}
+	return "map[string]bool{" + strings.Join(leave, ", ") + "}"
+}
+
+func assertFuncName(obj object) string {
+	return "assert" + obj.Obj().Name() + "DeepCopied"
+}
+
+// generateAssertFunc generates the assertion that a value of obj, the i-th of
+// the types, spelled typ, is a deep copy of another. The references within the
+// skipped and copy-on-write selectors of obj are shared by design, and not
+// reported.
+func (g Generator) generateAssertFunc(i int, obj object, typ string) []byte {
+	var buf bytes.Buffer
+
+	kind := obj.Obj().Name()
+	name := assertFuncName(obj)
+
+	var shared []string
+	for _, l := range []SkipLists{g.skipLists, g.cowLists} {
+		for sel := range expandPromoted(l.Get(i), obj) {
+			shared = append(shared, fmt.Sprintf("%q: true", sel))
+		}
+	}
+	sort.Strings(shared)
+
+	skips := "nil"
+	if len(shared) > 0 {
+		skips = "map[string]bool{" + strings.Join(shared, ", ") + "}"
+	}
+
+	fmt.Fprintf(&buf, `// %s asserts that b is a deep copy of a: equal to
+// it, and sharing none of its slices, maps and pointers.
+func %s(t *testing.T, a, b %s) {
+	t.Helper()
+`, name, name, typ)
+
+	// Reset fields differ from their source by design.
+	if len(g.resetLists.Get(i)) > 0 {
+		g.warnf("WARNING: the copies of %s reset fields, so %s doesn't compare them with their source", kind, name)
+	} else {
+		fmt.Fprintf(&buf, `if !reflect.DeepEqual(a, b) {
+	t.Errorf("copy = %%v, want %%v", b, a)
+}
+`)
+	}
+
+	fmt.Fprintf(&buf, `for _, sel := range deepCopySharedReferences(reflect.ValueOf(a), reflect.ValueOf(b), "", %s) {
+	t.Errorf("copy shares %%s with its source", sel)
+}
+}`, skips)
+
+	return buf.Bytes()
+}
+
+// generateFill generates the function filling the source of the round-trip
+// tests, so their copies have slices, maps and pointers to copy.
+func (g Generator) generateFill() []byte {
+	return []byte(`// deepCopyFill fills v, down to depth, with a value for each basic type, and
+// an element in each slice, map and pointer, but for the selectors within
+// leave. Interfaces, functions and channels are left nil, and unexported
+// fields zero, as they can't be set through reflection.
+func deepCopyFill(v reflect.Value, sel string, leave map[string]bool, depth int) {
+	if leave[sel] || depth == 0 || !v.CanSet() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Complex64, reflect.Complex128:
+		v.SetComplex(1)
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		deepCopyFill(v.Elem(), sel, leave, depth-1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		deepCopyFill(v.Index(0), sel+"[]", leave, depth-1)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			deepCopyFill(v.Index(i), sel+"[]", leave, depth-1)
+		}
+	case reflect.Map:
+		e := reflect.New(v.Type().Elem()).Elem()
+		deepCopyFill(e, sel+"[]", leave, depth-1)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(reflect.Zero(v.Type().Key()), e)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fsel := v.Type().Field(i).Name
+			if sel != "" {
+				fsel = sel + "." + fsel
+			}
+			deepCopyFill(v.Field(i), fsel, leave, depth-1)
+		}
+	}
+}`)
+}
+
+// generateSharedReferences generates the function finding the references
+// shared by a copy with its source, for the assertions of each type. Like the
+// generated methods, it shares the pointers to the shared types, and leaves
+// interfaces, functions and channels out.
+func (g Generator) generateSharedReferences() []byte {
+	var buf bytes.Buffer
+
+	names := make([]string, 0, len(g.sharedPtrs))
+	for name := range g.sharedPtrs {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+
+	var shared string
+	if len(names) > 0 {
+		shared = fmt.Sprintf(`switch t := a.Type().Elem(); strings.TrimPrefix(t.PkgPath()+"."+t.Name(), ".") {
+		case %s:
+			return nil
+		}
+		`, strings.Join(names, ", "))
+	}
+
+	fmt.Fprintf(&buf, `// deepCopySharedReferences returns the selectors of the slices, maps and
+// pointers of b which are shared with a, but for those within skips.
+func deepCopySharedReferences(a, b reflect.Value, sel string, skips map[string]bool) []string {
+	if skips[sel] {
+		return nil
+	}
+
+	var shared []string
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			return nil
+		}
+		%s// Pointers to zero-sized values may be equal, without sharing anything.
+		if a.Pointer() == b.Pointer() && a.Type().Elem().Size() > 0 {
+			return []string{sel}
+		}
+		return deepCopySharedReferences(a.Elem(), b.Elem(), sel, skips)
+	case reflect.Slice:
+		if a.Len() == 0 || b.Len() == 0 {
+			return nil
+		}
+		if a.Pointer() == b.Pointer() {
+			shared = append(shared, sel)
+		}
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			shared = append(shared, deepCopySharedReferences(a.Index(i), b.Index(i), sel+"[]", skips)...)
+		}
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			shared = append(shared, deepCopySharedReferences(a.Index(i), b.Index(i), sel+"[]", skips)...)
+		}
+	case reflect.Map:
+		if a.IsNil() || b.IsNil() {
+			return nil
+		}
+		if a.Pointer() == b.Pointer() {
+			shared = append(shared, sel)
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			if v := b.MapIndex(iter.Key()); v.IsValid() {
+				shared = append(shared, deepCopySharedReferences(iter.Value(), v, sel+"[]", skips)...)
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			fsel := a.Type().Field(i).Name
+			if sel != "" {
+				fsel = sel + "." + fsel
+			}
+			shared = append(shared, deepCopySharedReferences(a.Field(i), b.Field(i), fsel, skips)...)
+		}
+	}
+
+	return shared
+}`, shared)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,98 @@
+package deepcopy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Validate parses and type-checks src, as generated for the package p, along
+// with the files of the package, and returns the first error. Files
+// generated by deep-copy are left out, as src replaces them. Source of
+// another package, generated with WithPackageName, is checked on its own.
+func (g Generator) Validate(src []byte, p *packages.Package) error {
+	fset := token.NewFileSet()
+	generated, err := parser.ParseFile(fset, "generated.go", src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("parsing generated source: %w", err)
+	}
+
+	path := p.PkgPath
+	files := []*ast.File{generated}
+	if generated.Name.Name == p.Name {
+		for _, name := range p.GoFiles {
+			f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", name, err)
+			}
+
+			if !generatedByDeepCopy(f) {
+				files = append(files, f)
+			}
+		}
+	} else {
+		path = generated.Name.Name
+	}
+
+	var first error
+	conf := types.Config{
+		Importer: packageImporter(p),
+		Error: func(err error) {
+			if first == nil {
+				first = err
+			}
+		},
+	}
+	_, _ = conf.Check(path, fset, files, nil)
+
+	return first
+}
+
+// generatedByDeepCopy reports whether f has the header of a file generated
+// by deep-copy.
+func generatedByDeepCopy(f *ast.File) bool {
+	for _, c := range f.Comments {
+		if c.Pos() > f.Package {
+			break
+		}
+
+		if strings.HasPrefix(c.Text(), "Code generated by deep-copy") {
+			return true
+		}
+	}
+
+	return false
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// packageImporter imports p and its dependencies as loaded, and other
+// packages, such as the ones only the generated source imports, from their
+// export data.
+func packageImporter(p *packages.Package) types.Importer {
+	deps := map[string]*types.Package{}
+	packages.Visit([]*packages.Package{p}, nil, func(dep *packages.Package) {
+		if dep.Types != nil {
+			deps[dep.PkgPath] = dep.Types
+		}
+	})
+
+	fallback := importer.Default()
+	return importerFunc(func(path string) (*types.Package, error) {
+		if t, ok := deps[path]; ok {
+			return t, nil
+		}
+
+		return fallback.Import(path)
+	})
+}
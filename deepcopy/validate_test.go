@@ -0,0 +1,45 @@
+package deepcopy
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	p, err := LoadPackage("../testdata/golden/namedptrs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator()
+
+	t.Run("generated source", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := g.Generate(&buf, []string{"Holder", "Tree"}, p); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, g.Validate(buf.Bytes(), p))
+	})
+
+	t.Run("type error", func(t *testing.T) {
+		src := []byte(`package namedptrs
+
+func (o Tree) DeepCopy() Tree {
+	var cp Tree = o
+	retV := o.L.DeepCopy()
+	cp.L = &retV
+	return cp
+}
+`)
+		err := g.Validate(src, p)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "o.L.DeepCopy undefined")
+		}
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		assert.Error(t, g.Validate([]byte("package namedptrs\n\nfunc {"), p))
+	})
+}
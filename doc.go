@@ -15,4 +15,8 @@
 // members, or map members. To achieve that, selectors can be specified in the
 // optional comma-separated --skip flag. Multiple --skip flags can be
 // specified, to match the number of --type flags.
+//
+// Map keys are assigned as-is, unless their map selector is given in the
+// optional --copy-keys flag. Copying keys that hold pointers changes their
+// identity within the map, so a warning is printed in that case.
 package main
@@ -1,12 +1,15 @@
 package main
 
 import (
-	"errors"
+	"bytes"
 	"flag"
 	"fmt"
+	"go/token"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/globusdigital/deep-copy/deepcopy"
@@ -16,12 +19,60 @@ import (
 var (
 	pointerReceiverF = flag.Bool("pointer-receiver", false, "the generated receiver type")
 	maxDepthF        = flag.Int("maxdepth", 0, "max depth of deep copying")
+	shallowDepthF    = flag.Bool("shallow-on-maxdepth", false, "mark the values copied shallowly below the max depth with a comment")
+	strictDepthF     = flag.Bool("strict-maxdepth", false, "fail instead of only warning when values are copied shallowly below the max depth")
 	methodF          = flag.String("method", "DeepCopy", "deep copy method name")
+	methodTmplF      = flag.String("method-template", "", "text/template naming the method of each type, expanded with the type name as .Type, e.g. Copy{{.Type}}, instead of --method")
+	methodCommentF   = flag.String("method-comment", "", "text/template of the doc comment of each method, expanded with .Method, .Type and .Result, e.g. \"{{.Method}} returns a deep copy of {{.Type}}.\"")
+	forwardRefsF     = flag.Bool("forward-refs", false, "copy struct types of the package without a method through the method anyway, assuming it is generated separately")
+	transitiveF      = flag.Bool("transitive", false, "also generate the method of every struct type of the package without one, reachable from the given types")
+	manifestF        = flag.Bool("field-manifest", false, "list the fields of each struct type, with their types, in the comment of its method")
+	sourceCommentsF  = flag.Bool("source-comments", false, "reference the file and line defining the type in the comment of each method")
+	ifaceHelpersF    = flag.Bool("interface-helpers", false, "copy values of the interface types of the package declaring the method through a function generated once per type")
+	interfacesF      = flag.String("interfaces", "share", "how interface values are copied: share, or switch over the generated types")
+	allocCounterF    = flag.String("alloc-counter", "", "name of a package-level atomic.Int64 to declare, counting the allocations of the generated methods")
+	interfaceLitF    = flag.Bool("interface-literal", false, "render the empty interface as interface{} instead of any, for toolchains before Go 1.18")
+	nilSafeF         = flag.Bool("nil-safe", false, "return nil when the method is called on a nil pointer receiver")
+	copierF          = flag.String("copier", "", "name of a generic interface to declare, implemented by the generated types")
+	explicitFieldsF  = flag.Bool("explicit-fields", false, "assign each field of the copy explicitly, instead of copying the whole struct value first")
+	channelsF        = flag.String("channels", "recreate", "how channels are copied: recreate, share-signals for chan struct{} only, or share")
+	errorsF          = flag.String("errors", "share", "how errors are copied: share, wrap with fmt.Errorf(\"%w\"), or skip, leaving them nil")
+	chanBuffersF     = flag.Bool("copy-channel-buffers", false, "copy the elements buffered in recreated channels into the new ones. only safe if no other goroutine uses the channels during the copy")
+	nilPointersF     = flag.Bool("materialize-nil-pointers", false, "point nil pointers to a newly allocated zero value in the copy, instead of keeping them nil")
+	helperDepthF     = flag.Int("helper-depth", 0, "copy struct types nested at least this deep with a helper function per type. 0 inlines all copies")
+	standaloneF      = flag.Bool("standalone", false, "generate a function per type, e.g. DeepCopyFoo(o Foo) Foo, instead of a method")
+	copyIntoF        = flag.Bool("copy-into", false, "also generate a method copying into a destination given by the caller, wrapped by the generated method")
+	reuseMapsF       = flag.Bool("reuse-maps", false, "clear and refill the maps of the destination given to --copy-into methods, with Go 1.21 or later")
+	copyAllKeysF     = flag.Bool("copy-all-keys", false, "deeply copy the keys of all maps but pointers, as if each was given with --copy-keys")
+	ptrVariantF      = flag.Bool("pointer-variant", false, "also generate a variant of each method with a pointer receiver, e.g. DeepCopyPtr() *Foo")
+	fallibleF        = flag.Bool("fallible", false, "generate methods returning an error along with the copy, propagating the errors of DeepCopyE methods")
+	nolintF          = flag.String("nolint", "", "comma-separated linters to exempt the generated functions from with a //nolint directive, e.g. gocyclo,funlen")
+	constraintF      = flag.String("build-constraint", "", "build constraint to write above the header of the generated file, e.g. !ignore_autogenerated")
+	packageNameF     = flag.String("package-name", "", "name of the package of the generated file, if other than the package of the types. requires --standalone")
+	verboseF         = flag.Bool("verbose", false, "log the walk of each type as a tree of its selectors and the actions taken on them, e.g. recurse, skip or reuse")
+	validateF        = flag.Bool("validate", false, "type-check the generated file with the package before writing it, failing on the first error")
+	outputDirF       = flag.String("output-dir", "", "directory to write a file per type to, e.g. foo_deepcopy.go, instead of -o")
+	assertionsF      = flag.Bool("test-assertions", false, "also write a function per type into the round-trip tests, asserting that a value is a deep copy of another, sharing none of its references")
+	packageDocF      = flag.String("package-doc", "", "package doc comment, written unless the package already has one")
 
 	typesF     typesVal
 	skipsF     skipsVal
+	keysF      skipsVal
+	resetsF    skipsVal
+	cowsF      skipsVal
+	includesF  skipsVal
+	oneOfF     skipsVal
 	outputF    outputVal
+	testOutF   outputVal
 	buildTagsF buildTagsVal
+	tagSkipsF  tagSkipsVal
+	sharedF    typesVal
+	valuesF    typesVal
+	excludedF  typesVal
+	methodsF   = pairsVal{format: "Type=Name"}
+	resultsF   = pairsVal{format: "Type=Name"}
+	allocsF    = pairsVal{format: "Type=Expression"}
+	aliasesF   = pairsVal{format: "path=alias", valid: token.IsIdentifier}
 )
 
 type typesVal []string
@@ -107,6 +158,38 @@ func (f *outputVal) Open() (io.WriteCloser, error) {
 	return f.file, nil
 }
 
+// pairsVal is a flag of Key=Value pairs, one per flag. format spells a pair
+// in errors, e.g. Type=Name, and valid, if set, checks the value.
+type pairsVal struct {
+	m      map[string]string
+	format string
+	valid  func(v string) bool
+}
+
+func (p *pairsVal) String() string {
+	parts := make([]string, 0, len(p.m))
+	for k, v := range p.m {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+
+	return strings.Join(parts, ",")
+}
+
+func (p *pairsVal) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" || v == "" || p.valid != nil && !p.valid(v) {
+		return fmt.Errorf("expected %s, got %q", p.format, s)
+	}
+
+	if p.m == nil {
+		p.m = map[string]string{}
+	}
+	p.m[k] = v
+
+	return nil
+}
+
 type buildTagsVal []string
 
 func (b *buildTagsVal) String() string {
@@ -118,10 +201,53 @@ func (b *buildTagsVal) Set(v string) error {
 	return nil
 }
 
+type tagSkipsVal []string
+
+func (t *tagSkipsVal) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagSkipsVal) Set(v string) error {
+	if !strings.Contains(v, ":") {
+		return fmt.Errorf("expected key:value, got %q", v)
+	}
+
+	*t = append(*t, v)
+	return nil
+}
+
+// Options returns a generator option for each key:value pair, skipping the
+// fields whose tag value for key equals value.
+func (t tagSkipsVal) Options() []deepcopy.GeneratorOption {
+	opts := make([]deepcopy.GeneratorOption, 0, len(t))
+	for _, kv := range t {
+		key, value, _ := strings.Cut(kv, ":")
+		opts = append(opts, deepcopy.WithTagSkip(key, func(v string) bool {
+			return v == value
+		}))
+	}
+
+	return opts
+}
+
 func init() {
-	flag.Var(&typesF, "type", "the concrete type. Multiple flags can be specified")
+	flag.Var(&typesF, "type", "the concrete type, or * for all exported struct, slice and map types. Multiple flags can be specified")
+	flag.Var(&excludedF, "exclude-type", "type to leave out of --type *. Multiple flags can be specified")
 	flag.Var(&skipsF, "skip", "comma-separated field/slice/map selectors to shallow copy. Multiple flags can be specified")
+	flag.Var(&keysF, "copy-keys", "comma-separated map selectors whose keys are deeply copied. Multiple flags can be specified")
+	flag.Var(&resetsF, "reset", "comma-separated field selectors to set to their zero value in the copy. Multiple flags can be specified")
+	flag.Var(&includesF, "include", "comma-separated field/slice/map selectors to deeply copy, shallow copying the other fields. Multiple flags can be specified")
+	flag.Var(&cowsF, "cow", "comma-separated field selectors shared with the source, marked as copy-on-write. Multiple flags can be specified")
+	flag.Var(&oneOfF, "one-of", "comma-separated union fields of which exactly one must be set, checked when copying. Multiple flags can be specified")
+	flag.Var(&resultsF, "result-type", "Type=Result type to return the copy of the given type as, with the same underlying type. Multiple flags can be specified")
+	flag.Var(&methodsF, "method-for", "Type=Method name of the method of the given type, instead of --method. Multiple flags can be specified")
 	flag.Var(&outputF, "o", "the output file to write to. Defaults to STDOUT")
+	flag.Var(&testOutF, "test-o", "the output file to write round-trip tests to, guarded by the "+deepcopy.TestBuildTag+" build tag")
+	flag.Var(&tagSkipsF, "skip-tag", "key:value struct tag of fields to shallow copy, e.g. json:-. Multiple flags can be specified")
+	flag.Var(&sharedF, "share-pointer", "type, qualified by its package path, whose pointers are shared instead of copied. Multiple flags can be specified")
+	flag.Var(&valuesF, "value-type", "type, qualified by its package path, whose values are copied by assignment without descending into them. Multiple flags can be specified")
+	flag.Var(&aliasesF, "import-alias", "path=alias naming the package of the import path by the alias in the generated file, e.g. example.com/v2/models=models2. Multiple flags can be specified")
+	flag.Var(&allocsF, "allocator", "Type=Expression allocating the values pointed to by pointers to the type, qualified by its package path, e.g. example.com/pkg.Foo=pool.GetFoo(). Multiple flags can be specified")
 	flag.Var(&buildTagsF, "tags", "comma-separated build tags to add to generated file")
 }
 
@@ -136,44 +262,230 @@ func main() {
 		log.Fatalln("No package path given")
 	}
 
+	if *methodTmplF != "" && flagSet("method") {
+		log.Fatalln("--method and --method-template are mutually exclusive")
+	}
+
+	var ifaces deepcopy.InterfacePolicy
+	switch *interfacesF {
+	case "share":
+		ifaces = deepcopy.ShareInterfaces
+	case "switch":
+		ifaces = deepcopy.SwitchInterfaces
+	default:
+		log.Fatalln("unknown interface policy:", *interfacesF)
+	}
+
+	var chans deepcopy.ChannelPolicy
+	switch *channelsF {
+	case "recreate":
+		chans = deepcopy.RecreateChannels
+	case "share-signals":
+		chans = deepcopy.ShareSignalChannels
+	case "share":
+		chans = deepcopy.ShareChannels
+	default:
+		log.Fatalln("unknown channel policy:", *channelsF)
+	}
+
+	var errs deepcopy.ErrorPolicy
+	switch *errorsF {
+	case "share":
+		errs = deepcopy.ShareErrors
+	case "wrap":
+		errs = deepcopy.WrapErrors
+	case "skip":
+		errs = deepcopy.SkipErrors
+	default:
+		log.Fatalln("unknown error policy:", *errorsF)
+	}
+
 	sl := deepcopy.SkipLists(skipsF)
-	generator := deepcopy.NewGenerator(
+	generator := deepcopy.NewGenerator(append([]deepcopy.GeneratorOption{
 		deepcopy.IsPtrRecv(*pointerReceiverF),
 		deepcopy.WithMethodName(*methodF),
+		deepcopy.WithMethodNames(methodsF.m),
+		deepcopy.WithMethodNameTemplate(*methodTmplF),
+		deepcopy.WithMethodComment(*methodCommentF),
+		deepcopy.WithResultTypes(resultsF.m),
 		deepcopy.WithSkipLists(sl),
+		deepcopy.WithKeyCopyLists(deepcopy.SkipLists(keysF)),
+		deepcopy.WithDeepCopyMapKeys(*copyAllKeysF),
+		deepcopy.WithResetLists(deepcopy.SkipLists(resetsF)),
+		deepcopy.WithCopyOnWriteLists(deepcopy.SkipLists(cowsF)),
+		deepcopy.WithIncludeLists(deepcopy.SkipLists(includesF)),
+		deepcopy.WithOneOfLists(deepcopy.SkipLists(oneOfF)),
 		deepcopy.WithMaxDepth(*maxDepthF),
+		deepcopy.WithShallowOnMaxDepth(*shallowDepthF),
+		deepcopy.WithStrictMaxDepth(*strictDepthF),
 		deepcopy.WithBuildTags(buildTagsF),
-	)
+		deepcopy.WithImportAliases(aliasesF.m),
+		deepcopy.WithBuildConstraint(*constraintF),
+		deepcopy.WithNolintDirectives(nolintLinters(*nolintF)...),
+		deepcopy.WithPackageDoc(*packageDocF),
+		deepcopy.WithPackageName(*packageNameF),
+		deepcopy.WithInterfacePolicy(ifaces),
+		deepcopy.WithInterfaceHelpers(*ifaceHelpersF),
+		deepcopy.WithChannelPolicy(chans),
+		deepcopy.WithChannelBuffers(*chanBuffersF),
+		deepcopy.WithErrorPolicy(errs),
+		deepcopy.WithForwardReferences(*forwardRefsF),
+		deepcopy.WithTransitiveTypes(*transitiveF),
+		deepcopy.WithSourceComments(*sourceCommentsF),
+		deepcopy.WithFieldManifest(*manifestF),
+		deepcopy.WithSharedPointers(sharedF...),
+		deepcopy.WithValueTypes(valuesF...),
+		deepcopy.WithAllocators(allocsF.m),
+		deepcopy.WithMaterializedNilPointers(*nilPointersF),
+		deepcopy.WithExcludedTypes(excludedF...),
+		deepcopy.WithAllocCounter(*allocCounterF),
+		deepcopy.WithInterfaceLiteral(*interfaceLitF),
+		deepcopy.WithNilSafety(*nilSafeF),
+		deepcopy.WithCopierInterface(*copierF),
+		deepcopy.WithExplicitFields(*explicitFieldsF),
+		deepcopy.WithHelperDepth(*helperDepthF),
+		deepcopy.WithLogger(log.Default()),
+		deepcopy.WithVerbose(*verboseF),
+		deepcopy.WithStandalone(*standaloneF),
+		deepcopy.WithCopyInto(*copyIntoF),
+		deepcopy.WithMapReuse(*reuseMapsF),
+		deepcopy.WithFallible(*fallibleF),
+		deepcopy.WithPointerVariant(*ptrVariantF),
+		deepcopy.WithCopyAssertions(*assertionsF),
+	}, tagSkipsF.Options()...)...)
+
+	if *outputDirF != "" {
+		err := runEach(generator, *outputDirF, flag.Args()[0], typesF)
+		if err != nil {
+			log.Fatalln("Error generating deep copy methods:", err)
+		}
+	} else {
+		output, err := outputF.Open()
+		if err != nil {
+			log.Fatalln("Error initializing output file:", err)
+		}
 
-	output, err := outputF.Open()
+		err = run(generator, output, flag.Args()[0], typesF)
+		if err != nil {
+			log.Fatalln("Error generating deep copy method:", err)
+		}
+
+		output.Close()
+	}
+
+	if testOutF.file != nil {
+		testOutput, err := testOutF.Open()
+		if err != nil {
+			log.Fatalln("Error initializing test output file:", err)
+		}
+
+		err = runTests(generator, testOutput, flag.Args()[0], typesF)
+		if err != nil {
+			log.Fatalln("Error generating round-trip tests:", err)
+		}
+
+		testOutput.Close()
+	}
+}
+
+// nolintLinters returns the comma-separated linters of the --nolint flag.
+func nolintLinters(v string) []string {
+	if v == "" {
+		return nil
+	}
+
+	return strings.Split(v, ",")
+}
+
+// flagSet reports whether the flag of the given name was set on the command
+// line.
+func flagSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+func run(
+	g deepcopy.Generator, w io.Writer, path string, types typesVal,
+) error {
+	p, err := deepcopy.LoadPackage(path)
+	if err != nil {
+		return fmt.Errorf("loading package: %v", err)
+	}
+
+	names, err := resolveTypes(g, types, p)
+	if err != nil {
+		return err
+	}
+
+	if !*validateF {
+		return g.Generate(w, names, p)
+	}
+
+	var buf bytes.Buffer
+	if err := g.Generate(&buf, names, p); err != nil {
+		return err
+	}
+
+	if err := g.Validate(buf.Bytes(), p); err != nil {
+		return fmt.Errorf("validating generated code: %w", err)
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// runEach writes the methods of each type to its own file in dir, named
+// after the type, e.g. foo_deepcopy.go for Foo.
+func runEach(
+	g deepcopy.Generator, dir string, path string, types typesVal,
+) error {
+	p, err := deepcopy.LoadPackage(path)
 	if err != nil {
-		log.Fatalln("Error initializing output file:", err)
+		return fmt.Errorf("loading package: %v", err)
 	}
 
-	err = run(generator, output, flag.Args()[0], typesF)
+	names, err := resolveTypes(g, types, p)
 	if err != nil {
-		log.Fatalln("Error generating deep copy method:", err)
+		return err
 	}
 
-	output.Close()
+	return g.GenerateEach(func(kind string) (io.WriteCloser, error) {
+		return os.Create(filepath.Join(dir, strings.ToLower(kind)+"_deepcopy.go"))
+	}, names, p)
 }
 
-func run(
+func runTests(
 	g deepcopy.Generator, w io.Writer, path string, types typesVal,
 ) error {
-	packages, err := load(path)
+	p, err := deepcopy.LoadPackage(path)
 	if err != nil {
 		return fmt.Errorf("loading package: %v", err)
 	}
-	if len(packages) == 0 {
-		return errors.New("no package found")
+
+	names, err := resolveTypes(g, types, p)
+	if err != nil {
+		return err
 	}
 
-	return g.Generate(w, types, packages[0])
+	return g.GenerateTests(w, names, p)
 }
 
-func load(patterns string) ([]*packages.Package, error) {
-	return packages.Load(&packages.Config{
-		Mode: packages.NeedName | packages.NeedFiles | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedImports,
-	}, patterns)
+// resolveTypes returns the types to generate, all of them for "*".
+func resolveTypes(g deepcopy.Generator, types typesVal, p *packages.Package) ([]string, error) {
+	if len(types) != 1 || types[0] != "*" {
+		return types, nil
+	}
+
+	names := g.AllTypes(p)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no type to generate in %q", p.Name)
+	}
+
+	return names, nil
 }
@@ -2,7 +2,17 @@ package main
 
 import (
 	"bytes"
+	"flag"
+	"go/build/constraint"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/globusdigital/deep-copy/deepcopy"
@@ -16,9 +26,12 @@ func Test_run(t *testing.T) {
 		path      string
 		pointer   bool
 		skips     skipsVal
+		keys      skipsVal
+		resets    skipsVal
 		maxdepth  int
 		buildTags []string
 		method    string
+		opts      []deepcopy.GeneratorOption
 		want      []byte
 	}{
 		{name: "foo", types: typesVal{"Foo"}, path: "./testdata", want: []byte(FooFile)},
@@ -44,7 +57,58 @@ func Test_run(t *testing.T) {
 		{name: "issue 15, parent has child pointer, pointer receiver", pointer: true, types: typesVal{"ParentHasChildPointer", "Child"}, path: "./testdata", want: []byte(I15ParentHasChildPointerPointerRecv)},
 		{name: "issue 17, with maxdepth", types: typesVal{"Depth1"}, pointer: true, maxdepth: 2, path: "./testdata", want: []byte(Issue17MaxDepth)},
 		{name: "alias import", types: typesVal{"Data"}, path: "./testdata/import_alias", want: []byte(AliasImport)},
+		{name: "map with value key, deep copy keys", types: typesVal{"MapWithValueKey"}, keys: skipsVal{{"M[k]": struct{}{}}}, path: "./testdata", want: []byte(MapWithValueKeyCopied)},
+		{name: "map with pointer key, deep copy keys", types: typesVal{"MapWithPointerKey"}, keys: skipsVal{{"M[k]": struct{}{}}}, path: "./testdata", want: []byte(MapWithPointerKeyCopied)},
+		{name: "map with pointer key", types: typesVal{"MapWithPointerKey"}, path: "./testdata", want: []byte(MapWithPointerKeyAssigned)},
+		{name: "embedded interface", types: typesVal{"EmbedsInterface", "EmbedsCopier"}, path: "./testdata", want: []byte(EmbeddedInterface)},
+		{name: "skip by json tag", types: typesVal{"TaggedSecrets"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithTagSkip("json", func(v string) bool { return v == "-" })}, want: []byte(TaggedSecretsSkipJSON)},
+		{name: "skip by custom tag", types: typesVal{"TaggedSecrets"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithTagSkip("secret", func(v string) bool { return v == "true" })}, want: []byte(TaggedSecretsSkipSecret)},
+		{name: "embedded pointer with slice", types: typesVal{"EmbedsPointer"}, path: "./testdata", want: []byte(EmbeddedPointer)},
+		{name: "embedded pointer chain with slice", types: typesVal{"EmbedsPointerChain"}, path: "./testdata", want: []byte(EmbeddedPointerChain)},
+		{name: "package doc", types: typesVal{"Gamma"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithPackageDoc("Package testdata holds test data.\nIt is generated.")}, want: []byte(PackageDocAdded)},
+		{name: "package doc, already present", types: typesVal{"Data"}, path: "./testdata/package_doc", opts: []deepcopy.GeneratorOption{deepcopy.WithPackageDoc("Package package_doc holds test data.")}, want: []byte(PackageDocPresent)},
+		{name: "map of interface keys and values, type switch", types: typesVal{"DynamicConfig", "ConfigEntry"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithInterfacePolicy(deepcopy.SwitchInterfaces)}, want: []byte(MapAnyAny)},
+		{name: "map of interface keys and values, type switch, pointer receiver", types: typesVal{"DynamicConfig", "ConfigEntry"}, pointer: true, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithInterfacePolicy(deepcopy.SwitchInterfaces)}, want: []byte(MapAnyAnyPointer)},
+		{name: "map of interface keys and values", types: typesVal{"DynamicConfig", "ConfigEntry"}, path: "./testdata", want: []byte(MapAnyAnyShared)},
+		{name: "type parameter without core type", types: typesVal{"Text"}, path: "./testdata", want: []byte(TypeParamWithoutCoreType)},
+		{name: "issue 12, nested slices, skip slice members", types: typesVal{"I12NestedSlices"}, skips: skipsVal{{"Slices[i]": struct{}{}}}, path: "./testdata", want: []byte(Issue12NestedSlicesSkipMembers)},
+		{name: "in-package type not requested, forward references", types: typesVal{"Owner"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithForwardReferences(true)}, want: []byte(OwnerForwardReferences)},
+		{name: "in-package type not requested, transitive", types: typesVal{"Owner"}, pointer: true, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithTransitiveTypes(true)}, want: []byte(OwnerTransitive)},
+		{name: "reset fields", types: typesVal{"Record"}, resets: skipsVal{{"ID": struct{}{}, "Created": struct{}{}, "Cache": struct{}{}, "Parts[i].ID": struct{}{}, "Parts[i].Valid": struct{}{}}}, path: "./testdata", want: []byte(RecordReset)},
+		{name: "value and pointer of the same type", types: typesVal{"Holder", "Held"}, path: "./testdata", want: []byte(ValueAndPointerOfSameType)},
+		{name: "value and pointer of the same type, pointer receiver", types: typesVal{"Holder", "Held"}, pointer: true, path: "./testdata", want: []byte(ValueAndPointerOfSameTypePointerRecv)},
+		{name: "source comments", types: typesVal{"Gamma", "SlicePointer"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithSourceComments(true)}, want: []byte(SourceComments)},
+		{name: "types shadowing identifiers", types: typesVal{"Error", "Reader", "Map", "item", "o", "cp"}, path: "./testdata/shadowing", want: []byte(ShadowingNames)},
+		{name: "time location pointers are shared", types: typesVal{"Schedule"}, path: "./testdata/shared_pointers", want: []byte(SharedTimeLocation)},
+		{name: "map with collapsing key copies", types: typesVal{"MapWithVersionedKey"}, keys: skipsVal{{"M[k]": struct{}{}}}, path: "./testdata", want: []byte(MapWithVersionedKeyCopied)},
+		{name: "fallible map with collapsing key copies", types: typesVal{"MapWithVersionedKey"}, keys: skipsVal{{"M[k]": struct{}{}}}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithFallible(true)}, want: []byte(MapWithVersionedKeyFallible)},
+		{name: "anonymous struct slice elements", types: typesVal{"AnonymousSliceElem"}, path: "./testdata", want: []byte(AnonymousSliceElem)},
+		{name: "anonymous struct map values", types: typesVal{"AnonymousMapValue"}, path: "./testdata", want: []byte(AnonymousMapValue)},
+		{name: "copy-on-write fields", types: typesVal{"Catalog"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithCopyOnWriteLists(deepcopy.SkipLists{{"Items": struct{}{}, "Index": struct{}{}}})}, want: []byte(CatalogCopyOnWrite)},
+		{name: "union with pointer variants", types: typesVal{"Shape"}, path: "./testdata", want: []byte(ShapeUnion)},
+		{name: "union with exactly one variant check", types: typesVal{"Shape"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithOneOfLists(deepcopy.SkipLists{{"Circle": struct{}{}, "Square": struct{}{}}})}, want: []byte(ShapeUnionOneOf)},
+		{name: "allocation counter", types: typesVal{"Foo"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithAllocCounter("DeepCopyAllocs")}, want: []byte(FooAllocCounter)},
+		{name: "empty interfaces rendered as any", types: typesVal{"AnyFields"}, path: "./testdata", want: []byte(AnyFieldsAny)},
+		{name: "empty interfaces rendered as interface{}", types: typesVal{"AnyFields"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithInterfaceLiteral(true)}, want: []byte(AnyFieldsInterfaceLiteral)},
+		{name: "lock holders reuse their copy methods", types: typesVal{"Service"}, path: "./testdata/locks", want: []byte(LockHoldersReuse)},
+		{name: "nil-safe pointer receiver", types: typesVal{"Foo"}, path: "./testdata", pointer: true, opts: []deepcopy.GeneratorOption{deepcopy.WithNilSafety(true)}, want: []byte(FooNilSafe)},
+		{name: "slice of interfaces with nil elements, type switch", types: typesVal{"Tree", "Leaf", "Branch"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithInterfacePolicy(deepcopy.SwitchInterfaces)}, want: []byte(InterfaceSliceSwitch)},
+		{name: "map of named slice with a DeepCopy method", types: typesVal{"TagsByName"}, path: "./testdata", want: []byte(TagsByNameFile)},
+		{name: "copier interface", types: typesVal{"Bar", "Baz"}, pointer: true, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithCopierInterface("DeepCopier")}, want: []byte(CopierInterface)},
+		{name: "pointers to a type with a value receiver DeepCopy", types: typesVal{"ConcreteRefs"}, path: "./testdata", want: []byte(ConcreteRefsFile)},
+		{name: "struct value copy", types: typesVal{"Snapshot"}, path: "./testdata", want: []byte(SnapshotValueCopy)},
+		{name: "explicit field assignments", types: typesVal{"Snapshot"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithExplicitFields(true)}, want: []byte(SnapshotExplicitFields)},
+		{name: "explicit field assignments, pointer receiver", types: typesVal{"Foo"}, pointer: true, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithExplicitFields(true)}, want: []byte(FooExplicitFieldsPointer)},
+		{name: "signal channel, recreated", types: typesVal{"Worker"}, path: "./testdata", want: []byte(WorkerRecreateChannels)},
+		{name: "signal channel, shared", types: typesVal{"Worker"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithChannelPolicy(deepcopy.ShareSignalChannels)}, want: []byte(WorkerShareSignals)},
+		{name: "signal channel, skipped", types: typesVal{"Worker"}, skips: skipsVal{{"done": struct{}{}}}, path: "./testdata", want: []byte(WorkerSkipSignal)},
+		{name: "build constraint", types: typesVal{"Gamma"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithBuildConstraint("!ignore_autogenerated")}, want: []byte(GammaBuildConstraint)},
+		{name: "issue 17, with maxdepth, shallow copies marked", types: typesVal{"Depth1"}, pointer: true, maxdepth: 2, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithShallowOnMaxDepth(true)}, want: []byte(Issue17MaxDepthShallow)},
+		{name: "skip by deepcopy tag", types: typesVal{"TaggedPolicy"}, path: "./testdata", want: []byte(TaggedPolicySkip)},
+		{name: "map keys, assigned", types: typesVal{"MapKeys"}, path: "./testdata", want: []byte(MapKeysShared)},
+		{name: "map keys, copy all keys", types: typesVal{"MapKeys"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithDeepCopyMapKeys(true)}, want: []byte(MapKeysCopied)},
 		{name: "using build tags", types: typesVal{"Foo"}, path: "./testdata", buildTags: []string{"!myTag", "anotherOne"}, want: []byte(FooFileBuildTags)},
+		{name: "build constraint and tags", types: typesVal{"Gamma"}, path: "./testdata", buildTags: []string{"anotherOne"}, opts: []deepcopy.GeneratorOption{deepcopy.WithBuildConstraint("!ignore_autogenerated || debug")}, want: []byte(GammaBuildConstraintTags)},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -52,13 +116,15 @@ func Test_run(t *testing.T) {
 			if tt.method != "" {
 				method = tt.method
 			}
-			g := deepcopy.NewGenerator(
+			g := deepcopy.NewGenerator(append([]deepcopy.GeneratorOption{
 				deepcopy.IsPtrRecv(tt.pointer),
 				deepcopy.WithMethodName(method),
 				deepcopy.WithSkipLists(deepcopy.SkipLists(tt.skips)),
+				deepcopy.WithKeyCopyLists(deepcopy.SkipLists(tt.keys)),
+				deepcopy.WithResetLists(deepcopy.SkipLists(tt.resets)),
 				deepcopy.WithMaxDepth(tt.maxdepth),
 				deepcopy.WithBuildTags(tt.buildTags),
-			)
+			}, tt.opts...)...)
 			var buf bytes.Buffer
 			err := run(g, &buf, tt.path, tt.types)
 			if err != nil {
@@ -72,6 +138,533 @@ func Test_run(t *testing.T) {
 	}
 }
 
+func Test_run_warnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		types typesVal
+		path  string
+		opts  []deepcopy.GeneratorOption
+		want  string
+	}{
+		{name: "value key", types: typesVal{"MapWithValueKey"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithKeyCopyLists(deepcopy.SkipLists{{"M[k]": struct{}{}}})}, want: "WARNING: deep copying key of M[k] with its method can collapse distinct keys"},
+		{name: "pointer key", types: typesVal{"MapWithPointerKey"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithKeyCopyLists(deepcopy.SkipLists{{"M[k]": struct{}{}}})}, want: "WARNING: deep copying key of M[k]"},
+		{name: "type parameter without core type", types: typesVal{"Text"}, path: "./testdata", want: "WARNING: T has no single core type in testdata.StringOrBytes. copying cp.Value shallowly"},
+		{name: "buffers of directional channels", types: typesVal{"Queue"}, path: "./testdata/golden/chanbufs", opts: []deepcopy.GeneratorOption{deepcopy.WithChannelBuffers(true)}, want: "WARNING: buffered elements of the directional channel Results are not copied"},
+		{name: "references in unexported fields", types: typesVal{"Holder"}, path: "./testdata/golden/hidden", want: "WARNING: copying ext.Opaque shares the references in its items field. define a DeepCopy or Clone method to copy them"},
+		{name: "verbose skip", types: typesVal{"Order"}, path: "./testdata/golden/includes", opts: []deepcopy.GeneratorOption{deepcopy.WithVerbose(true), deepcopy.WithSkipLists(deepcopy.SkipLists{{"Lines[i].Tags": {}}})}, want: "      Lines[i].Tags []string: skip\n"},
+		{name: "verbose reuse", types: typesVal{"Reuse"}, path: "./testdata/golden/reuse", opts: []deepcopy.GeneratorOption{deepcopy.WithVerbose(true)}, want: "    Values[i] reuse.Value: reuse\n"},
+		{name: "key copied with its method", types: typesVal{"MapWithVersionedKey"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithKeyCopyLists(deepcopy.SkipLists{{"M[k]": struct{}{}}})}, want: "WARNING: deep copying key of M[k] with its method can collapse distinct keys into one entry, which fails the copy"},
+		{name: "max depth", types: typesVal{"Depth1"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithMaxDepth(2)}, want: "WARNING: reached max depth 2. stop recursion at github.com/globusdigital/deep-copy/testdata.Depth1.a2"},
+		{name: "lock holders with copy methods", types: typesVal{"Service"}, path: "./testdata/locks"},
+		{name: "lock holder without copy method", types: typesVal{"Registry"}, path: "./testdata/locks", want: "WARNING: Registry holds a lock, which is copied along with the value it is called on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			g := deepcopy.NewGenerator(append(tt.opts, deepcopy.WithLogger(log.New(&logs, "", 0)))...)
+			err := run(g, io.Discard, tt.path, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" && logs.Len() > 0 {
+				t.Errorf("unexpected warning: %s", logs.String())
+			}
+			if !strings.Contains(logs.String(), tt.want) {
+				t.Errorf("warning %q not logged; logs: %s", tt.want, logs.String())
+			}
+		})
+	}
+}
+
+func Test_run_errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		types typesVal
+		path  string
+		opts  []deepcopy.GeneratorOption
+		want  string
+	}{
+		{name: "shadowed builtin", types: typesVal{"Data"}, path: "./testdata/shadowing_builtin", want: `"len" in "shadowing_builtin" shadows a builtin used by the generated code`},
+		{name: "method name collides with a field", types: typesVal{"FuncField"}, path: "./testdata", want: `FuncField has a field named DeepCopy, which collides with the generated method; choose another method name, e.g. "Clone"`},
+		{name: "method name collides with a method", types: typesVal{"WrongDeepCopy"}, path: "./testdata", want: `WrongDeepCopy has a method DeepCopy(shallow bool) WrongDeepCopy, which collides with the generated method; choose another method name, e.g. "Clone"`},
+		{name: "alias of an unnamed type", types: typesVal{"Servers"}, path: "./testdata/golden/aliases", want: `Servers is an alias of []Server, which can not have methods; generate standalone functions instead`},
+		{name: "methods in another package", types: typesVal{"Gamma"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithPackageName("testdatacopy")}, want: `methods of the types in "testdata" can not be declared in package "testdatacopy"; generate standalone functions instead`},
+		{name: "pointer variant of pointer receivers", types: typesVal{"Config"}, path: "./testdata/golden/variants", opts: []deepcopy.GeneratorOption{deepcopy.IsPtrRecv(true), deepcopy.WithPointerVariant(true)}, want: `the pointer variant requires value receivers`},
+		{name: "fallible copies of a recursive type", types: typesVal{"Node"}, path: "./testdata/golden/cycles", opts: []deepcopy.GeneratorOption{deepcopy.WithFallible(true)}, want: `fallible copies of the recursive type Node require a max depth`},
+		{name: "strict max depth", types: typesVal{"Depth1"}, path: "./testdata", opts: []deepcopy.GeneratorOption{deepcopy.WithMaxDepth(2), deepcopy.WithStrictMaxDepth(true)}, want: `reached max depth 2, copying shallowly: github.com/globusdigital/deep-copy/testdata.Depth1.a1, github.com/globusdigital/deep-copy/testdata.Depth1.a2`},
+		{name: "result type not found", types: typesVal{"Customer"}, path: "./testdata/golden/results", opts: []deepcopy.GeneratorOption{deepcopy.WithResultTypes(map[string]string{"Customer": "Client"})}, want: `result type Client of Customer not found in "results"`},
+		{name: "result type of another underlying type", types: typesVal{"Customer"}, path: "./testdata/golden/results", opts: []deepcopy.GeneratorOption{deepcopy.WithResultTypes(map[string]string{"Customer": "Order"})}, want: `Customer can not be converted to its result type Order`},
+		{name: "result type copied into a destination", types: typesVal{"Customer"}, path: "./testdata/golden/results", opts: []deepcopy.GeneratorOption{deepcopy.WithResultTypes(map[string]string{"Customer": "CustomerCopy"}), deepcopy.WithCopyInto(true)}, want: `result types can not be copied into a destination`},
+		{name: "fallible copies into a destination", types: typesVal{"Message"}, path: "./testdata/golden/fallible", opts: []deepcopy.GeneratorOption{deepcopy.WithFallible(true), deepcopy.WithCopyInto(true)}, want: `fallible methods can not copy into a destination`},
+		{name: "map reuse without a destination", types: typesVal{"Frame"}, path: "./testdata/golden/reusemaps", opts: []deepcopy.GeneratorOption{deepcopy.WithMapReuse(true)}, want: `maps can only be reused by copies into a destination`},
+		{name: "method name template of an invalid name", types: typesVal{"Outer"}, path: "./testdata/golden/methodtmpl", opts: []deepcopy.GeneratorOption{deepcopy.WithMethodNameTemplate("Copy {{.Type}}")}, want: `the method name template expands to "Copy Outer" for Outer, which is not an identifier`},
+		{name: "method name template of an unknown field", types: typesVal{"Outer"}, path: "./testdata/golden/methodtmpl", opts: []deepcopy.GeneratorOption{deepcopy.WithMethodNameTemplate("Copy{{.Name}}")}, want: `expanding the method name template for Outer`},
+		{name: "type declared in several functions", types: typesVal{"Temp"}, path: "./testdata/golden/scopes", want: `ambiguous type, declared in 2 functions: scopes.go:16:7, scopes.go:23:7`},
+		{name: "method comment template of an unknown field", types: typesVal{"Account"}, path: "./testdata/golden/comments", opts: []deepcopy.GeneratorOption{deepcopy.WithMethodComment("{{.Name}} copies")}, want: `expanding the method comment template`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := deepcopy.NewGenerator(tt.opts...)
+			err := run(g, io.Discard, tt.path, tt.types)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+var update = flag.Bool("update", false, "update the golden files of TestGolden, Test_runEach and Test_runTestsAssertions")
+
+// TestGolden generates the methods of each package in testdata/golden, and
+// compares them to the package's golden file. Run with -update to rewrite
+// the golden files.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		dir   string
+		types typesVal
+		opts  []deepcopy.GeneratorOption
+	}{
+		{dir: "structs", types: typesVal{"Outer"}},
+		{dir: "slices", types: typesVal{"Slices"}},
+		{dir: "maps", types: typesVal{"Maps"}},
+		{dir: "pointers", types: typesVal{"Pointers"}},
+		{dir: "chans", types: typesVal{"Chans"}},
+		{dir: "reuse", types: typesVal{"Reuse"}},
+		{dir: "arrays", types: typesVal{"Ring"}},
+		{dir: "interfaces", types: typesVal{"Event"}},
+		{dir: "cycles", types: typesVal{"Node", "Ring"}},
+		{dir: "helpers", types: typesVal{"Forest"}, opts: []deepcopy.GeneratorOption{deepcopy.WithHelperDepth(2)}},
+		{dir: "standalone", types: typesVal{"Order", "Customer", "Tree"}, opts: []deepcopy.GeneratorOption{deepcopy.WithStandalone(true)}},
+		{dir: "values", types: typesVal{"Event"}},
+		{dir: "syncs", types: typesVal{"Store"}, opts: []deepcopy.GeneratorOption{deepcopy.IsPtrRecv(true)}},
+		{dir: "crosspkg", types: typesVal{"Holder"}},
+		{dir: "pkgname", types: typesVal{"Account", "User", "Page"}, opts: []deepcopy.GeneratorOption{deepcopy.WithStandalone(true), deepcopy.WithPackageName("pkgnamecopy")}},
+		{dir: "imports", types: typesVal{"Imports"}},
+		{dir: "aliases", types: typesVal{"ConfigAlias"}},
+		{dir: "methods", types: typesVal{"Ledger", "Account"}, opts: []deepcopy.GeneratorOption{deepcopy.WithMethodNames(map[string]string{"Account": "Clone"})}},
+		{dir: "named", types: typesVal{"Batch"}, opts: []deepcopy.GeneratorOption{deepcopy.WithMethodNames(map[string]string{"IDs": "Clone", "Index": "Clone"})}},
+		{dir: "elements", types: typesVal{"Elements"}},
+		{dir: "fallible", types: typesVal{"Message", "Part"}, opts: []deepcopy.GeneratorOption{deepcopy.WithFallible(true)}},
+		{dir: "embedded", types: typesVal{"Doc", "Outer"}, opts: []deepcopy.GeneratorOption{
+			deepcopy.WithSkipLists(deepcopy.SkipLists{{"Tags": {}, "Meta.Notes": {}, "Shadowed": {}, "Items[i].Labels": {}}}),
+			deepcopy.WithResetLists(deepcopy.SkipLists{{"Items[i].ID": {}}}),
+		}},
+		{dir: "all", types: typesVal{"*"}, opts: []deepcopy.GeneratorOption{deepcopy.WithExcludedTypes("Excluded")}},
+		{dir: "funcs", types: typesVal{"Hooks"}, opts: []deepcopy.GeneratorOption{deepcopy.WithSkipLists(deepcopy.SkipLists{{"Skipped": {}, "Default.Validate": {}}})}},
+		{dir: "variants", types: typesVal{"Config", "Pair"}, opts: []deepcopy.GeneratorOption{deepcopy.WithPointerVariant(true), deepcopy.WithNilSafety(true)}},
+		{dir: "manifest", types: typesVal{"Customer", "IDs"}, opts: []deepcopy.GeneratorOption{deepcopy.WithFieldManifest(true)}},
+		{dir: "namedptrs", types: typesVal{"Holder", "Tree"}},
+		{dir: "elementskips", types: typesVal{"Order"}, opts: []deepcopy.GeneratorOption{deepcopy.WithSkipLists(deepcopy.SkipLists{{"Items[].Secret": {}, "ByName[][].Tags": {}, "Grid[i][].Secret": {}, "Notes[]": {}}})}},
+		{dir: "namedslices", types: typesVal{"Event"}},
+		{dir: "generics", types: typesVal{"Box", "Inner", "Store", "Pair", "Library"}},
+		{dir: "allocators", types: typesVal{"Request"}, opts: []deepcopy.GeneratorOption{deepcopy.WithAllocators(map[string]string{"github.com/globusdigital/deep-copy/testdata/golden/allocators.Buffer": "getBuffer()"})}},
+		{dir: "inline", types: typesVal{"Doc", "Export"}},
+		{dir: "chanbufs", types: typesVal{"Queue"}, opts: []deepcopy.GeneratorOption{deepcopy.WithChannelBuffers(true), deepcopy.WithChannelPolicy(deepcopy.ShareSignalChannels)}},
+		{dir: "genericifaces", types: typesVal{"Holder", "Box"}, opts: []deepcopy.GeneratorOption{deepcopy.IsPtrRecv(true), deepcopy.WithInterfacePolicy(deepcopy.SwitchInterfaces)}},
+		{dir: "ifacehelpers", types: typesVal{"Drawing", "Layer"}, opts: []deepcopy.GeneratorOption{deepcopy.WithInterfaceHelpers(true)}},
+		{dir: "results", types: typesVal{"Customer", "Order"}, opts: []deepcopy.GeneratorOption{deepcopy.WithResultTypes(map[string]string{"Customer": "CustomerCopy"})}},
+		{dir: "hidden", types: typesVal{"Holder"}},
+		{dir: "nolint", types: typesVal{"Node", "Report"}, opts: []deepcopy.GeneratorOption{deepcopy.WithNolintDirectives("gocyclo", "funlen"), deepcopy.WithHelperDepth(2)}},
+		{dir: "nesting", types: typesVal{"Nesting"}},
+		{dir: "nilpointers", types: typesVal{"Contact"}, opts: []deepcopy.GeneratorOption{deepcopy.WithMaterializedNilPointers(true)}},
+		{dir: "methodtmpl", types: typesVal{"Inner", "Outer"}, opts: []deepcopy.GeneratorOption{deepcopy.WithMethodNameTemplate("Copy{{.Type}}")}},
+		{dir: "ptrptrs", types: typesVal{"Holder"}},
+		{dir: "comments", types: typesVal{"Account", "Team"}, opts: []deepcopy.GeneratorOption{deepcopy.WithMethodComment("{{.Method}} returns a deep copy of {{.Type}}.\n\nSee TICKET-123.")}},
+		{dir: "includes", types: typesVal{"Order"}, opts: []deepcopy.GeneratorOption{
+			deepcopy.WithIncludeLists(deepcopy.SkipLists{{"Address.Lines": {}, "Lines[i].Tags": {}, "Contacts": {}}}),
+			deepcopy.WithSkipLists(deepcopy.SkipLists{{"Contacts[i].Geo": {}}}),
+		}},
+		{dir: "errors", types: typesVal{"Result"}, opts: []deepcopy.GeneratorOption{deepcopy.WithErrorPolicy(deepcopy.WrapErrors)}},
+		{dir: "scopes", types: typesVal{"Config"}},
+		{dir: "importas", types: typesVal{"Request"}, opts: []deepcopy.GeneratorOption{deepcopy.WithImportAliases(map[string]string{"net/url": "neturl"})}},
+		{dir: "into", types: typesVal{"Frame", "Samples", "Span"}, opts: []deepcopy.GeneratorOption{deepcopy.IsPtrRecv(true), deepcopy.WithCopyInto(true)}},
+		{dir: "mutual", types: typesVal{"A", "B", "Team", "Member"}},
+		{dir: "reusemaps", types: typesVal{"Frame", "Labels"}, opts: []deepcopy.GeneratorOption{deepcopy.IsPtrRecv(true), deepcopy.WithCopyInto(true), deepcopy.WithMapReuse(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			path := filepath.Join("testdata", "golden", tt.dir)
+			golden := filepath.Join(path, tt.dir+"_deepcopy.go.golden")
+
+			var buf bytes.Buffer
+			err := run(deepcopy.NewGenerator(tt.opts...), &buf, "./"+path, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := append(normalizeComment(buf.Bytes()), '\n')
+
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("run() diff = %s", diff)
+			}
+		})
+	}
+}
+
+// Test_runMutualCycles copies cyclic values of mutually recursive types with
+// the generated methods.
+func Test_runMutualCycles(t *testing.T) {
+	for _, pointer := range []bool{false, true} {
+		var buf bytes.Buffer
+		err := run(deepcopy.NewGenerator(deepcopy.IsPtrRecv(pointer)), &buf, "./testdata/golden/mutual", typesVal{"A", "B", "Team", "Member"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test := MutualCyclesTest
+		if pointer {
+			test = strings.ReplaceAll(test, "cp := a.DeepCopy()", "cp := *a.DeepCopy()")
+			test = strings.ReplaceAll(test, "cp2 := team.DeepCopy()", "cp2 := *team.DeepCopy()")
+		}
+		out, err := goTest(t, "./testdata/golden/mutual", map[string][]byte{
+			"mutual_deepcopy.go":      buf.Bytes(),
+			"mutual_deepcopy_test.go": []byte(test),
+		})
+		if err != nil {
+			t.Errorf("go test: %v\n%s", err, out)
+		}
+	}
+}
+
+// goTest runs go test with args on a copy of the package in dir, along with
+// the given files, in a module of its own.
+func goTest(t *testing.T, dir string, files map[string][]byte, args ...string) ([]byte, error) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping go test of generated code in short mode")
+	}
+
+	tmp := t.TempDir()
+	sources, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range sources {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(src)] = b
+	}
+	files["go.mod"] = []byte("module " + filepath.Base(dir) + "\n\ngo 1.21\n")
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(tmp, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", append(append([]string{"test"}, args...), ".")...)
+	cmd.Dir = tmp
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	return cmd.CombinedOutput()
+}
+
+// Test_runReuseMaps copies into destinations holding maps, which are cleared
+// and refilled, and benchmarks their reuse against allocating the copies.
+func Test_runReuseMaps(t *testing.T) {
+	g := deepcopy.NewGenerator(deepcopy.IsPtrRecv(true), deepcopy.WithCopyInto(true), deepcopy.WithMapReuse(true))
+	var buf bytes.Buffer
+	err := run(g, &buf, "./testdata/golden/reusemaps", typesVal{"Frame", "Labels"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := goTest(t, "./testdata/golden/reusemaps", map[string][]byte{
+		"reusemaps_deepcopy.go":      buf.Bytes(),
+		"reusemaps_deepcopy_test.go": []byte(ReuseMapsTest),
+	}, "-bench", ".", "-benchtime", "100x")
+	if err != nil {
+		t.Errorf("go test: %v\n%s", err, out)
+	}
+}
+
+// Test_runEach generates a file per type into a temporary directory, and
+// compares each to its golden file in testdata/golden/each.
+func Test_runEach(t *testing.T) {
+	dir := t.TempDir()
+	err := runEach(deepcopy.NewGenerator(), dir, "./testdata/golden/each", typesVal{"Page", "Schedule"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"page", "schedule"} {
+		got, err := os.ReadFile(filepath.Join(dir, name+"_deepcopy.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(normalizeComment(got), '\n')
+
+		golden := filepath.Join("testdata", "golden", "each", name+"_deepcopy.go.golden")
+		if *update {
+			if err := os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("%s diff = %s", name, diff)
+		}
+	}
+}
+
+// Test_runEachPackageDoc checks that the package doc is written to the first
+// file only.
+func Test_runEachPackageDoc(t *testing.T) {
+	dir := t.TempDir()
+	g := deepcopy.NewGenerator(deepcopy.WithPackageDoc("Package each holds test data."))
+	err := runEach(g, dir, "./testdata/golden/each", typesVal{"Page", "Schedule"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"page": true, "schedule": false} {
+		got, err := os.ReadFile(filepath.Join(dir, name+"_deepcopy.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has := bytes.Contains(got, []byte("// Package each holds test data.\n")); has != want {
+			t.Errorf("%s has package doc = %v, want %v", name, has, want)
+		}
+	}
+}
+
+func TestGenerateForObjects(t *testing.T) {
+	p, err := deepcopy.LoadPackage("./testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo, ok := p.Types.Scope().Lookup("Foo").(*types.TypeName)
+	if !ok {
+		t.Fatal("Foo not found")
+	}
+
+	var buf bytes.Buffer
+	err = deepcopy.NewGenerator().GenerateForObjects(&buf, []*types.TypeName{foo}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := normalizeComment(buf.Bytes())
+	if diff := cmp.Diff(got, []byte(FooFile)); diff != "" {
+		t.Errorf("GenerateForObjects() diff = %s", diff)
+	}
+}
+
+func Test_runTests(t *testing.T) {
+	g := deepcopy.NewGenerator(deepcopy.IsPtrRecv(true))
+	var buf bytes.Buffer
+	err := runTests(g, &buf, "./testdata", typesVal{"Foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := normalizeComment(buf.Bytes())
+	if diff := cmp.Diff(got, []byte(FooRoundTripTestFile)); diff != "" {
+		t.Errorf("runTests() diff = %s", diff)
+	}
+
+	var expr constraint.Expr
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if constraint.IsGoBuild(line) {
+			expr, err = constraint.Parse(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if expr == nil {
+		t.Fatal("no build constraint found")
+	}
+	if expr.Eval(func(string) bool { return false }) {
+		t.Error("round-trip tests are built by default")
+	}
+	if !expr.Eval(func(tag string) bool { return tag == deepcopy.TestBuildTag }) {
+		t.Errorf("round-trip tests are not built with the %s tag", deepcopy.TestBuildTag)
+	}
+}
+
+// Test_runTestsConstraint checks that the round-trip tests require the
+// TestBuildTag along with the build constraint and tags of the methods, in a
+// single //go:build line.
+func Test_runTestsConstraint(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []deepcopy.GeneratorOption
+		want string
+	}{
+		{name: "build constraint", opts: []deepcopy.GeneratorOption{deepcopy.WithBuildConstraint("!ignore_autogenerated")}, want: "//go:build deepcopytest && !ignore_autogenerated"},
+		{name: "build tags", opts: []deepcopy.GeneratorOption{deepcopy.WithBuildTags([]string{"linux"})}, want: "//go:build deepcopytest && linux"},
+		{name: "build constraint and tags", opts: []deepcopy.GeneratorOption{deepcopy.WithBuildConstraint("linux || darwin"), deepcopy.WithBuildTags([]string{"!race"})}, want: "//go:build deepcopytest && (linux || darwin) && !race"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := runTests(deepcopy.NewGenerator(tt.opts...), &buf, "./testdata", typesVal{"Foo"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if constraint.IsGoBuild(line) {
+					lines = append(lines, line)
+				}
+			}
+			if diff := cmp.Diff(lines, []string{tt.want}); diff != "" {
+				t.Errorf("build constraints diff = %s", diff)
+			}
+		})
+	}
+}
+
+// Test_runTestsAssertions compares the round-trip tests with their copy
+// assertions to the golden file in testdata/golden/assertions.
+func Test_runTestsAssertions(t *testing.T) {
+	g := deepcopy.NewGenerator(
+		deepcopy.WithCopyAssertions(true),
+		deepcopy.WithSkipLists(deepcopy.SkipLists{{"Cache": {}}}),
+	)
+	var buf bytes.Buffer
+	err := runTests(g, &buf, "./testdata/golden/assertions", typesVal{"Order"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := append(normalizeComment(buf.Bytes()), '\n')
+
+	golden := filepath.Join("testdata", "golden", "assertions", "assertions_deepcopy_test.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("runTests() diff = %s", diff)
+	}
+}
+
+// Test_runTestsAssertionsShallow checks that the copy assertions of the
+// round-trip tests report a slice copied shallowly.
+func Test_runTestsAssertionsShallow(t *testing.T) {
+	var methods, tests bytes.Buffer
+	g := deepcopy.NewGenerator(deepcopy.WithSkipLists(deepcopy.SkipLists{{"Cache": {}, "Items": {}}}))
+	if err := run(g, &methods, "./testdata/golden/assertions", typesVal{"Order"}); err != nil {
+		t.Fatal(err)
+	}
+	g = deepcopy.NewGenerator(deepcopy.WithCopyAssertions(true), deepcopy.WithSkipLists(deepcopy.SkipLists{{"Cache": {}}}))
+	if err := runTests(g, &tests, "./testdata/golden/assertions", typesVal{"Order"}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := goTest(t, "./testdata/golden/assertions", map[string][]byte{
+		"assertions_deepcopy.go":      methods.Bytes(),
+		"assertions_deepcopy_test.go": tests.Bytes(),
+	}, "-tags", deepcopy.TestBuildTag)
+	if err == nil {
+		t.Fatalf("go test passed, want a failure\n%s", out)
+	}
+	if !bytes.Contains(out, []byte("copy shares Items with its source")) {
+		t.Errorf("go test output doesn't report Items\n%s", out)
+	}
+}
+
+// Test_runTestsFilled runs the round-trip tests, on a filled source, of the
+// generated methods.
+func Test_runTestsFilled(t *testing.T) {
+	for _, pointer := range []bool{false, true} {
+		g := deepcopy.NewGenerator(deepcopy.IsPtrRecv(pointer))
+		var methods, tests bytes.Buffer
+		if err := run(g, &methods, "./testdata/golden/assertions", typesVal{"Order", "Item"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runTests(g, &tests, "./testdata/golden/assertions", typesVal{"Order", "Item"}); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := goTest(t, "./testdata/golden/assertions", map[string][]byte{
+			"assertions_deepcopy.go":      methods.Bytes(),
+			"assertions_deepcopy_test.go": tests.Bytes(),
+		}, "-tags", deepcopy.TestBuildTag)
+		if err != nil {
+			t.Errorf("go test: %v\n%s", err, out)
+		}
+	}
+}
+
+func Test_runTestsGenerics(t *testing.T) {
+	for _, pointer := range []bool{false, true} {
+		g := deepcopy.NewGenerator(deepcopy.IsPtrRecv(pointer), deepcopy.WithCopyAssertions(true))
+		var methods, tests bytes.Buffer
+		if err := run(g, &methods, "./testdata/golden/generics", typesVal{"Box", "Inner", "Pair"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runTests(g, &tests, "./testdata/golden/generics", typesVal{"Box", "Pair"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"a, b Box[any])", "a, b Pair[any, any])"} {
+			if !strings.Contains(tests.String(), want) {
+				t.Errorf("tests = %s, want %q", tests.String(), want)
+			}
+		}
+
+		out, err := goTest(t, "./testdata/golden/generics", map[string][]byte{
+			"generics_deepcopy.go":      methods.Bytes(),
+			"generics_deepcopy_test.go": tests.Bytes(),
+		}, "-tags", deepcopy.TestBuildTag)
+		if err != nil {
+			t.Errorf("go test: %v\n%s", err, out)
+		}
+	}
+
+	// The constraint of Store has no core type, and any doesn't satisfy it.
+	err := runTests(deepcopy.NewGenerator(), io.Discard, "./testdata/golden/generics", typesVal{"Store"})
+	if want := "instantiating the generic type Store for its round-trip test"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("runTests() error = %v, want %q", err, want)
+	}
+}
+
+func Test_pairsVal(t *testing.T) {
+	p := pairsVal{format: "Type=Name"}
+	for _, v := range []string{"Foo=Copy", "Bar=Clone"} {
+		if err := p.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := p.String(), "Bar=Clone,Foo=Copy"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for _, v := range []string{"Foo", "=Copy", "Foo="} {
+		if err := p.Set(v); err == nil || !strings.Contains(err.Error(), "expected Type=Name") {
+			t.Errorf("Set(%q) error = %v, want expected Type=Name", v, err)
+		}
+	}
+
+	aliases := pairsVal{format: "path=alias", valid: token.IsIdentifier}
+	if err := aliases.Set("example.com/v2/models=models-2"); err == nil {
+		t.Error("Set() of an alias which is not an identifier succeeded")
+	}
+}
+
 var re = regexp.MustCompile(`Code generated by deep-copy.*; DO NOT EDIT.`)
 
 func normalizeComment(in []byte) []byte {
@@ -89,7 +682,7 @@ func (o Foo) DeepCopy() Foo {
 	if o.Map != nil {
 		cp.Map = make(map[string]*Bar, len(o.Map))
 		for k2, v2 := range o.Map {
-			var cp_Map_v2 *Bar
+			var cp_Map_v2 *Bar = v2
 			if v2 != nil {
 				cp_Map_v2 = new(Bar)
 				*cp_Map_v2 = *v2
@@ -120,7 +713,7 @@ func (o *Foo) DeepCopy() *Foo {
 	if o.Map != nil {
 		cp.Map = make(map[string]*Bar, len(o.Map))
 		for k2, v2 := range o.Map {
-			var cp_Map_v2 *Bar
+			var cp_Map_v2 *Bar = v2
 			if v2 != nil {
 				cp_Map_v2 = new(Bar)
 				*cp_Map_v2 = *v2
@@ -146,12 +739,14 @@ func (o *Foo) DeepCopy() *Foo {
 package testdata
 
 // DeepCopy generates a deep copy of *Foo
+//
+// skips: Slice
 func (o *Foo) DeepCopy() *Foo {
 	var cp Foo = *o
 	if o.Map != nil {
 		cp.Map = make(map[string]*Bar, len(o.Map))
 		for k2, v2 := range o.Map {
-			var cp_Map_v2 *Bar
+			var cp_Map_v2 *Bar = v2
 			if v2 != nil {
 				cp_Map_v2 = new(Bar)
 				*cp_Map_v2 = *v2
@@ -173,6 +768,8 @@ func (o *Foo) DeepCopy() *Foo {
 package testdata
 
 // DeepCopy generates a deep copy of Foo
+//
+// skips: Map[]
 func (o Foo) DeepCopy() Foo {
 	var cp Foo = o
 	if o.Map != nil {
@@ -216,10 +813,12 @@ func (o Alpha) DeepCopy() Alpha {
 package testdata
 
 // DeepCopy generates a deep copy of SlicePointer
+//
+// skips: []
 func (o SlicePointer) DeepCopy() SlicePointer {
 	var cp SlicePointer = o
 	if o != nil {
-		cp = make([]*int, len(o))
+		cp = make(SlicePointer, len(o))
 		copy(cp, o)
 	}
 	return cp
@@ -229,6 +828,8 @@ func (o SlicePointer) DeepCopy() SlicePointer {
 package testdata
 
 // DeepCopy generates a deep copy of Foo
+//
+// skips: Map[], ch
 func (o Foo) DeepCopy() Foo {
 	var cp Foo = o
 	if o.Map != nil {
@@ -245,6 +846,8 @@ func (o Foo) DeepCopy() Foo {
 }
 
 // DeepCopy generates a deep copy of Alpha
+//
+// skips: D, E
 func (o Alpha) DeepCopy() Alpha {
 	var cp Alpha = o
 	if o.B != nil {
@@ -264,7 +867,7 @@ func (o Foo) Clone() Foo {
 	if o.Map != nil {
 		cp.Map = make(map[string]*Bar, len(o.Map))
 		for k2, v2 := range o.Map {
-			var cp_Map_v2 *Bar
+			var cp_Map_v2 *Bar = v2
 			if v2 != nil {
 				cp_Map_v2 = new(Bar)
 				*cp_Map_v2 = *v2
@@ -340,11 +943,11 @@ func (o SomeStruct2) DeepCopy() SomeStruct2 {
 	if o.mapStruct != nil {
 		cp.mapStruct = make(map[string]SomeStruct, len(o.mapStruct))
 		for k2, v2 := range o.mapStruct {
-			var cp_mapStruct_v2 SomeStruct
+			var cp_mapStruct_v2 SomeStruct = v2
 			if v2.mapSlice != nil {
 				cp_mapStruct_v2.mapSlice = make(map[string][]string, len(v2.mapSlice))
 				for k4, v4 := range v2.mapSlice {
-					var cp_mapStruct_v2_mapSlice_v4 []string
+					var cp_mapStruct_v2_mapSlice_v4 []string = v4
 					if v4 != nil {
 						cp_mapStruct_v2_mapSlice_v4 = make([]string, len(v4))
 						copy(cp_mapStruct_v2_mapSlice_v4, v4)
@@ -368,7 +971,7 @@ func (o SomeStruct) DeepCopy() SomeStruct {
 	if o.mapSlice != nil {
 		cp.mapSlice = make(map[string][]string, len(o.mapSlice))
 		for k2, v2 := range o.mapSlice {
-			var cp_mapSlice_v2 []string
+			var cp_mapSlice_v2 []string = v2
 			if v2 != nil {
 				cp_mapSlice_v2 = make([]string, len(v2))
 				copy(cp_mapSlice_v2, v2)
@@ -385,7 +988,7 @@ func (o SomeStruct2) DeepCopy() SomeStruct2 {
 	if o.mapStruct != nil {
 		cp.mapStruct = make(map[string]SomeStruct, len(o.mapStruct))
 		for k2, v2 := range o.mapStruct {
-			var cp_mapStruct_v2 SomeStruct
+			var cp_mapStruct_v2 SomeStruct = v2
 			cp_mapStruct_v2 = v2.DeepCopy()
 			cp.mapStruct[k2] = cp_mapStruct_v2
 		}
@@ -462,7 +1065,7 @@ func (o I12StructWithMapOfSlices) DeepCopy() I12StructWithMapOfSlices {
 	if o.Sc1 != nil {
 		cp.Sc1 = make(map[string][]I12StructWithSlices, len(o.Sc1))
 		for k2, v2 := range o.Sc1 {
-			var cp_Sc1_v2 []I12StructWithSlices
+			var cp_Sc1_v2 []I12StructWithSlices = v2
 			if v2 != nil {
 				cp_Sc1_v2 = make([]I12StructWithSlices, len(v2))
 				copy(cp_Sc1_v2, v2)
@@ -596,23 +1199,20 @@ func (o Data) DeepCopy() Data {
 	return cp
 }`
 
-	FooFileBuildTags = `// Code generated by deep-copy; DO NOT EDIT.
+	FooFileBuildTags = `//go:build !myTag && anotherOne
+// +build !myTag,anotherOne
 
-//go:build !myTag
-//go:build anotherOne
-// +build !myTag
-// +build anotherOne
+// Code generated by deep-copy; DO NOT EDIT.
 
 package testdata
 
 // DeepCopy generates a deep copy of Foo
-//
 func (o Foo) DeepCopy() Foo {
 	var cp Foo = o
 	if o.Map != nil {
 		cp.Map = make(map[string]*Bar, len(o.Map))
 		for k2, v2 := range o.Map {
-			var cp_Map_v2 *Bar
+			var cp_Map_v2 *Bar = v2
 			if v2 != nil {
 				cp_Map_v2 = new(Bar)
 				*cp_Map_v2 = *v2
@@ -633,4 +1233,1503 @@ func (o Foo) DeepCopy() Foo {
 	}
 	return cp
 }`
+
+	MapWithValueKeyCopied = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of MapWithValueKey
+func (o MapWithValueKey) DeepCopy() MapWithValueKey {
+	var cp MapWithValueKey = o
+	if o.M != nil {
+		cp.M = make(map[ValueKey]string, len(o.M))
+		for k2, v2 := range o.M {
+			var cp_M_k2 ValueKey = k2
+			cp_M_k2 = k2.DeepCopy()
+			cp.M[cp_M_k2] = v2
+		}
+		if len(cp.M) != len(o.M) {
+			panic("deep copy of M[k] keys collapsed distinct entries")
+		}
+	}
+	return cp
+}`
+
+	MapWithVersionedKeyFallible = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+import (
+	"fmt"
+)
+
+// DeepCopy generates a deep copy of MapWithVersionedKey
+func (o MapWithVersionedKey) DeepCopy() (MapWithVersionedKey, error) {
+	var cp MapWithVersionedKey = o
+	if o.M != nil {
+		cp.M = make(map[VersionedKey]string, len(o.M))
+		for k2, v2 := range o.M {
+			var cp_M_k2 VersionedKey = k2
+			cp_M_k2 = k2.DeepCopy()
+			cp.M[cp_M_k2] = v2
+		}
+		if len(cp.M) != len(o.M) {
+			err := fmt.Errorf("deep copy of M[k] keys collapsed distinct entries")
+			return cp, err
+		}
+	}
+	return cp, nil
+}`
+
+	MapWithPointerKeyCopied = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of MapWithPointerKey
+func (o MapWithPointerKey) DeepCopy() MapWithPointerKey {
+	var cp MapWithPointerKey = o
+	if o.M != nil {
+		cp.M = make(map[PointerKey]string, len(o.M))
+		for k2, v2 := range o.M {
+			var cp_M_k2 PointerKey = k2
+			cp_M_k2 = k2.DeepCopy()
+			cp.M[cp_M_k2] = v2
+		}
+		if len(cp.M) != len(o.M) {
+			panic("deep copy of M[k] keys collapsed distinct entries")
+		}
+	}
+	return cp
+}`
+
+	MapWithPointerKeyAssigned = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of MapWithPointerKey
+func (o MapWithPointerKey) DeepCopy() MapWithPointerKey {
+	var cp MapWithPointerKey = o
+	if o.M != nil {
+		cp.M = make(map[PointerKey]string, len(o.M))
+		for k2, v2 := range o.M {
+			cp.M[k2] = v2
+		}
+	}
+	return cp
+}`
+
+	FooRoundTripTestFile = `//go:build deepcopytest
+// +build deepcopytest
+
+// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFooDeepCopyRoundTrip(t *testing.T) {
+	o := new(Foo)
+	deepCopyFill(reflect.ValueOf(o).Elem(), "", nil, 5)
+	cp := o.DeepCopy()
+	if !reflect.DeepEqual(o, cp) {
+		t.Errorf("Foo.DeepCopy() = %v, want %v", cp, o)
+	}
+}
+
+// deepCopyFill fills v, down to depth, with a value for each basic type, and
+// an element in each slice, map and pointer, but for the selectors within
+// leave. Interfaces, functions and channels are left nil, and unexported
+// fields zero, as they can't be set through reflection.
+func deepCopyFill(v reflect.Value, sel string, leave map[string]bool, depth int) {
+	if leave[sel] || depth == 0 || !v.CanSet() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Complex64, reflect.Complex128:
+		v.SetComplex(1)
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		deepCopyFill(v.Elem(), sel, leave, depth-1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		deepCopyFill(v.Index(0), sel+"[]", leave, depth-1)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			deepCopyFill(v.Index(i), sel+"[]", leave, depth-1)
+		}
+	case reflect.Map:
+		e := reflect.New(v.Type().Elem()).Elem()
+		deepCopyFill(e, sel+"[]", leave, depth-1)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(reflect.Zero(v.Type().Key()), e)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fsel := v.Type().Field(i).Name
+			if sel != "" {
+				fsel = sel + "." + fsel
+			}
+			deepCopyFill(v.Field(i), fsel, leave, depth-1)
+		}
+	}
+}`
+
+	EmbeddedInterface = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of EmbedsInterface
+func (o EmbedsInterface) DeepCopy() EmbedsInterface {
+	var cp EmbedsInterface = o
+	// WARNING: interface field Stringer copied shallowly
+	if o.Values != nil {
+		cp.Values = make([]int, len(o.Values))
+		copy(cp.Values, o.Values)
+	}
+	return cp
+}
+
+// DeepCopy generates a deep copy of EmbedsCopier
+func (o EmbedsCopier) DeepCopy() EmbedsCopier {
+	var cp EmbedsCopier = o
+	if o.Copier != nil {
+		cp.Copier = o.Copier.DeepCopy()
+	}
+	return cp
+}`
+
+	TaggedSecretsSkipJSON = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of TaggedSecrets
+func (o TaggedSecrets) DeepCopy() TaggedSecrets {
+	var cp TaggedSecrets = o
+	if o.Public != nil {
+		cp.Public = make([]string, len(o.Public))
+		copy(cp.Public, o.Public)
+	}
+	if o.Named != nil {
+		cp.Named = make([]string, len(o.Named))
+		copy(cp.Named, o.Named)
+	}
+	if o.Secret != nil {
+		cp.Secret = new(string)
+		*cp.Secret = *o.Secret
+	}
+	if o.NotSecret != nil {
+		cp.NotSecret = new(string)
+		*cp.NotSecret = *o.NotSecret
+	}
+	return cp
+}`
+
+	TaggedSecretsSkipSecret = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of TaggedSecrets
+func (o TaggedSecrets) DeepCopy() TaggedSecrets {
+	var cp TaggedSecrets = o
+	if o.Public != nil {
+		cp.Public = make([]string, len(o.Public))
+		copy(cp.Public, o.Public)
+	}
+	if o.Hidden != nil {
+		cp.Hidden = make([]string, len(o.Hidden))
+		copy(cp.Hidden, o.Hidden)
+	}
+	if o.Named != nil {
+		cp.Named = make([]string, len(o.Named))
+		copy(cp.Named, o.Named)
+	}
+	if o.NotSecret != nil {
+		cp.NotSecret = new(string)
+		*cp.NotSecret = *o.NotSecret
+	}
+	return cp
+}`
+
+	EmbeddedPointer = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of EmbedsPointer
+func (o EmbedsPointer) DeepCopy() EmbedsPointer {
+	var cp EmbedsPointer = o
+	if o.EmbeddedData != nil {
+		cp.EmbeddedData = new(EmbeddedData)
+		*cp.EmbeddedData = *o.EmbeddedData
+		if o.EmbeddedData.Data != nil {
+			cp.EmbeddedData.Data = make([]int, len(o.EmbeddedData.Data))
+			copy(cp.EmbeddedData.Data, o.EmbeddedData.Data)
+		}
+	}
+	return cp
+}`
+
+	EmbeddedPointerChain = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of EmbedsPointerChain
+func (o EmbedsPointerChain) DeepCopy() EmbedsPointerChain {
+	var cp EmbedsPointerChain = o
+	if o.EmbedsPointer != nil {
+		cp.EmbedsPointer = new(EmbedsPointer)
+		*cp.EmbedsPointer = *o.EmbedsPointer
+		if o.EmbedsPointer.EmbeddedData != nil {
+			cp.EmbedsPointer.EmbeddedData = new(EmbeddedData)
+			*cp.EmbedsPointer.EmbeddedData = *o.EmbedsPointer.EmbeddedData
+			if o.EmbedsPointer.EmbeddedData.Data != nil {
+				cp.EmbedsPointer.EmbeddedData.Data = make([]int, len(o.EmbedsPointer.EmbeddedData.Data))
+				copy(cp.EmbedsPointer.EmbeddedData.Data, o.EmbedsPointer.EmbeddedData.Data)
+			}
+		}
+	}
+	return cp
+}`
+
+	PackageDocAdded = `// Code generated by deep-copy; DO NOT EDIT.
+
+// Package testdata holds test data.
+// It is generated.
+package testdata
+
+// DeepCopy generates a deep copy of Gamma
+func (o Gamma) DeepCopy() Gamma {
+	var cp Gamma = o
+	return cp
+}`
+
+	PackageDocPresent = `// Code generated by deep-copy; DO NOT EDIT.
+
+package package_doc
+
+// DeepCopy generates a deep copy of Data
+func (o Data) DeepCopy() Data {
+	var cp Data = o
+	if o.Values != nil {
+		cp.Values = make([]int, len(o.Values))
+		copy(cp.Values, o.Values)
+	}
+	return cp
+}`
+
+	MapAnyAny = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of DynamicConfig
+func (o DynamicConfig) DeepCopy() DynamicConfig {
+	var cp DynamicConfig = o
+	if o.Values != nil {
+		cp.Values = make(map[any]any, len(o.Values))
+		for k2, v2 := range o.Values {
+			var cp_Values_v2 any = v2
+			if v2 != nil {
+				switch t3 := v2.(type) {
+				case DynamicConfig:
+					cp_Values_v2 = t3.DeepCopy()
+				case *DynamicConfig:
+					if t3 != nil {
+						retV := t3.DeepCopy()
+						cp_Values_v2 = &retV
+					}
+				case ConfigEntry:
+					cp_Values_v2 = t3.DeepCopy()
+				case *ConfigEntry:
+					if t3 != nil {
+						retV := t3.DeepCopy()
+						cp_Values_v2 = &retV
+					}
+				}
+			}
+			cp.Values[k2] = cp_Values_v2
+		}
+	}
+	return cp
+}
+
+// DeepCopy generates a deep copy of ConfigEntry
+func (o ConfigEntry) DeepCopy() ConfigEntry {
+	var cp ConfigEntry = o
+	if o.Tags != nil {
+		cp.Tags = make([]string, len(o.Tags))
+		copy(cp.Tags, o.Tags)
+	}
+	return cp
+}`
+
+	MapAnyAnyPointer = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of *DynamicConfig
+func (o *DynamicConfig) DeepCopy() *DynamicConfig {
+	var cp DynamicConfig = *o
+	if o.Values != nil {
+		cp.Values = make(map[any]any, len(o.Values))
+		for k2, v2 := range o.Values {
+			var cp_Values_v2 any = v2
+			if v2 != nil {
+				switch t3 := v2.(type) {
+				case DynamicConfig:
+					cp_Values_v2 = *t3.DeepCopy()
+				case *DynamicConfig:
+					if t3 != nil {
+						cp_Values_v2 = t3.DeepCopy()
+					}
+				case ConfigEntry:
+					cp_Values_v2 = *t3.DeepCopy()
+				case *ConfigEntry:
+					if t3 != nil {
+						cp_Values_v2 = t3.DeepCopy()
+					}
+				}
+			}
+			cp.Values[k2] = cp_Values_v2
+		}
+	}
+	return &cp
+}
+
+// DeepCopy generates a deep copy of *ConfigEntry
+func (o *ConfigEntry) DeepCopy() *ConfigEntry {
+	var cp ConfigEntry = *o
+	if o.Tags != nil {
+		cp.Tags = make([]string, len(o.Tags))
+		copy(cp.Tags, o.Tags)
+	}
+	return &cp
+}`
+
+	MapAnyAnyShared = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of DynamicConfig
+func (o DynamicConfig) DeepCopy() DynamicConfig {
+	var cp DynamicConfig = o
+	if o.Values != nil {
+		cp.Values = make(map[any]any, len(o.Values))
+		for k2, v2 := range o.Values {
+			cp.Values[k2] = v2
+		}
+	}
+	return cp
+}
+
+// DeepCopy generates a deep copy of ConfigEntry
+func (o ConfigEntry) DeepCopy() ConfigEntry {
+	var cp ConfigEntry = o
+	if o.Tags != nil {
+		cp.Tags = make([]string, len(o.Tags))
+		copy(cp.Tags, o.Tags)
+	}
+	return cp
+}`
+
+	TypeParamWithoutCoreType = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Text[T]
+func (o Text[T]) DeepCopy() Text[T] {
+	var cp Text[T] = o
+	if o.Lines != nil {
+		cp.Lines = make([]string, len(o.Lines))
+		copy(cp.Lines, o.Lines)
+	}
+	return cp
+}`
+
+	Issue12NestedSlicesSkipMembers = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of I12NestedSlices
+//
+// skips: Slices[]
+func (o I12NestedSlices) DeepCopy() I12NestedSlices {
+	var cp I12NestedSlices = o
+	if o.Slices != nil {
+		cp.Slices = make([][][]int, len(o.Slices))
+		copy(cp.Slices, o.Slices)
+	}
+	return cp
+}`
+
+	OwnerForwardReferences = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Owner
+func (o Owner) DeepCopy() Owner {
+	var cp Owner = o
+	if o.Pet != nil {
+		retV := o.Pet.DeepCopy()
+		cp.Pet = &retV
+	}
+	if o.Pets != nil {
+		cp.Pets = make([]Pet, len(o.Pets))
+		copy(cp.Pets, o.Pets)
+		for i2 := range o.Pets {
+			cp.Pets[i2] = o.Pets[i2].DeepCopy()
+		}
+	}
+	return cp
+}`
+
+	OwnerTransitive = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of *Owner
+func (o *Owner) DeepCopy() *Owner {
+	var cp Owner = *o
+	if o.Pet != nil {
+		cp.Pet = o.Pet.DeepCopy()
+	}
+	if o.Pets != nil {
+		cp.Pets = make([]Pet, len(o.Pets))
+		copy(cp.Pets, o.Pets)
+		for i2 := range o.Pets {
+			{
+				retV := o.Pets[i2].DeepCopy()
+				cp.Pets[i2] = *retV
+			}
+		}
+	}
+	return &cp
+}
+
+// DeepCopy generates a deep copy of *Pet
+func (o *Pet) DeepCopy() *Pet {
+	var cp Pet = *o
+	if o.Toys != nil {
+		cp.Toys = make([]string, len(o.Toys))
+		copy(cp.Toys, o.Toys)
+	}
+	if o.Vet != nil {
+		cp.Vet = o.Vet.DeepCopy()
+	}
+	return &cp
+}
+
+// DeepCopy generates a deep copy of *Vet
+func (o *Vet) DeepCopy() *Vet {
+	var cp Vet = *o
+	if o.Patients != nil {
+		cp.Patients = make(map[string]int, len(o.Patients))
+		for k2, v2 := range o.Patients {
+			cp.Patients[k2] = v2
+		}
+	}
+	return &cp
+}`
+
+	RecordReset = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Record
+func (o Record) DeepCopy() Record {
+	var cp Record = o
+	cp.ID = 0
+	cp.Created = Timestamp{}
+	cp.Cache = nil
+	if o.Tags != nil {
+		cp.Tags = make([]string, len(o.Tags))
+		copy(cp.Tags, o.Tags)
+	}
+	if o.Parts != nil {
+		cp.Parts = make([]RecordPart, len(o.Parts))
+		copy(cp.Parts, o.Parts)
+		for i2 := range o.Parts {
+			cp.Parts[i2].ID = ""
+			cp.Parts[i2].Valid = false
+		}
+	}
+	return cp
+}`
+
+	ValueAndPointerOfSameType = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Holder
+func (o Holder) DeepCopy() Holder {
+	var cp Holder = o
+	cp.A = o.A.DeepCopy()
+	if o.B != nil {
+		retV := o.B.DeepCopy()
+		cp.B = &retV
+	}
+	cp.C = o.C.DeepCopy()
+	if o.D != nil {
+		retV := o.D.DeepCopy()
+		cp.D = &retV
+	}
+	if o.Hs != nil {
+		cp.Hs = make([]Held, len(o.Hs))
+		copy(cp.Hs, o.Hs)
+		for i2 := range o.Hs {
+			cp.Hs[i2] = o.Hs[i2].DeepCopy()
+		}
+	}
+	return cp
+}
+
+// DeepCopy generates a deep copy of Held
+func (o Held) DeepCopy() Held {
+	var cp Held = o
+	if o.Values != nil {
+		cp.Values = make([]int, len(o.Values))
+		copy(cp.Values, o.Values)
+	}
+	return cp
+}`
+
+	ValueAndPointerOfSameTypePointerRecv = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of *Holder
+func (o *Holder) DeepCopy() *Holder {
+	var cp Holder = *o
+	{
+		retV := o.A.DeepCopy()
+		cp.A = *retV
+	}
+	if o.B != nil {
+		cp.B = o.B.DeepCopy()
+	}
+	{
+		retV := o.C.DeepCopy()
+		cp.C = *retV
+	}
+	if o.D != nil {
+		cp.D = o.D.DeepCopy()
+	}
+	if o.Hs != nil {
+		cp.Hs = make([]Held, len(o.Hs))
+		copy(cp.Hs, o.Hs)
+		for i2 := range o.Hs {
+			{
+				retV := o.Hs[i2].DeepCopy()
+				cp.Hs[i2] = *retV
+			}
+		}
+	}
+	return &cp
+}
+
+// DeepCopy generates a deep copy of *Held
+func (o *Held) DeepCopy() *Held {
+	var cp Held = *o
+	if o.Values != nil {
+		cp.Values = make([]int, len(o.Values))
+		copy(cp.Values, o.Values)
+	}
+	return &cp
+}`
+
+	SourceComments = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Gamma
+//
+// source: alpha.go:14
+func (o Gamma) DeepCopy() Gamma {
+	var cp Gamma = o
+	return cp
+}
+
+// DeepCopy generates a deep copy of SlicePointer
+//
+// source: foo.go:19
+func (o SlicePointer) DeepCopy() SlicePointer {
+	var cp SlicePointer = o
+	if o != nil {
+		cp = make(SlicePointer, len(o))
+		copy(cp, o)
+		for i := range o {
+			if o[i] != nil {
+				cp[i] = new(int)
+				*cp[i] = *o[i]
+			}
+		}
+	}
+	return cp
+}`
+
+	ShadowingNames = `// Code generated by deep-copy; DO NOT EDIT.
+
+package shadowing
+
+import (
+	github_com_globusdigital_deep_copy_testdata_import_alias_item "github.com/globusdigital/deep-copy/testdata/import_alias/item"
+)
+
+// DeepCopy generates a deep copy of Error
+func (o_ Error) DeepCopy() Error {
+	var cp_ Error = o_
+	if o_.Message != nil {
+		cp_.Message = new(string)
+		*cp_.Message = *o_.Message
+	}
+	return cp_
+}
+
+// DeepCopy generates a deep copy of Reader
+func (o_ Reader) DeepCopy() Reader {
+	var cp_ Reader = o_
+	if o_.Buf != nil {
+		cp_.Buf = make([]byte, len(o_.Buf))
+		copy(cp_.Buf, o_.Buf)
+	}
+	return cp_
+}
+
+// DeepCopy generates a deep copy of Map
+func (o_ Map) DeepCopy() Map {
+	var cp_ Map = o_
+	if o_ != nil {
+		cp_ = make(map[string][]int, len(o_))
+		for k, v := range o_ {
+			var cp__v []int = v
+			if v != nil {
+				cp__v = make([]int, len(v))
+				copy(cp__v, v)
+			}
+			cp_[k] = cp__v
+		}
+	}
+	return cp_
+}
+
+// DeepCopy generates a deep copy of item
+func (o_ item) DeepCopy() item {
+	var cp_ item = o_
+	if o_.Items != nil {
+		cp_.Items = make([]github_com_globusdigital_deep_copy_testdata_import_alias_item.Item, len(o_.Items))
+		copy(cp_.Items, o_.Items)
+	}
+	return cp_
+}
+
+// DeepCopy generates a deep copy of o
+func (o_ o) DeepCopy() o {
+	var cp_ o
+	o_.deepCopyVisited(&cp_, map[*o]*o{})
+	return cp_
+}
+
+// deepCopyVisited copies o_ into cp_, reusing the copies of the pointers in visited.
+func (o_ *o) deepCopyVisited(cp_ *o, visited map[*o]*o) {
+	*cp_ = *o_
+	if o_.Next != nil {
+		if c, ok := visited[o_.Next]; ok {
+			cp_.Next = c
+		} else {
+			cp_.Next = new(o)
+			visited[o_.Next] = cp_.Next
+			o_.Next.deepCopyVisited(cp_.Next, visited)
+		}
+	}
+	if o_.cp != nil {
+		retV := o_.cp.DeepCopy()
+		cp_.cp = &retV
+	}
+}
+
+// DeepCopy generates a deep copy of cp
+func (o_ cp) DeepCopy() cp {
+	var cp_ cp = o_
+	if o_.Values != nil {
+		cp_.Values = make([]int, len(o_.Values))
+		copy(cp_.Values, o_.Values)
+	}
+	return cp_
+}`
+
+	SharedTimeLocation = `// Code generated by deep-copy; DO NOT EDIT.
+
+package shared_pointers
+
+import (
+	"time"
+)
+
+// DeepCopy generates a deep copy of Schedule
+func (o Schedule) DeepCopy() Schedule {
+	var cp Schedule = o
+	if o.Locations != nil {
+		cp.Locations = make([]*time.Location, len(o.Locations))
+		copy(cp.Locations, o.Locations)
+	}
+	if o.Start != nil {
+		cp.Start = new(int)
+		*cp.Start = *o.Start
+	}
+	return cp
+}`
+
+	MapWithVersionedKeyCopied = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of MapWithVersionedKey
+func (o MapWithVersionedKey) DeepCopy() MapWithVersionedKey {
+	var cp MapWithVersionedKey = o
+	if o.M != nil {
+		cp.M = make(map[VersionedKey]string, len(o.M))
+		for k2, v2 := range o.M {
+			var cp_M_k2 VersionedKey = k2
+			cp_M_k2 = k2.DeepCopy()
+			cp.M[cp_M_k2] = v2
+		}
+		if len(cp.M) != len(o.M) {
+			panic("deep copy of M[k] keys collapsed distinct entries")
+		}
+	}
+	return cp
+}`
+
+	AnonymousSliceElem = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of AnonymousSliceElem
+func (o AnonymousSliceElem) DeepCopy() AnonymousSliceElem {
+	var cp AnonymousSliceElem = o
+	if o.Rows != nil {
+		cp.Rows = make([]struct{ X []int }, len(o.Rows))
+		copy(cp.Rows, o.Rows)
+		for i2 := range o.Rows {
+			if o.Rows[i2].X != nil {
+				cp.Rows[i2].X = make([]int, len(o.Rows[i2].X))
+				copy(cp.Rows[i2].X, o.Rows[i2].X)
+			}
+		}
+	}
+	return cp
+}`
+
+	AnonymousMapValue = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of AnonymousMapValue
+func (o AnonymousMapValue) DeepCopy() AnonymousMapValue {
+	var cp AnonymousMapValue = o
+	if o.ByName != nil {
+		cp.ByName = make(map[string]struct{ Y *Foo }, len(o.ByName))
+		for k2, v2 := range o.ByName {
+			var cp_ByName_v2 struct{ Y *Foo } = v2
+			if v2.Y != nil {
+				retV := v2.Y.DeepCopy()
+				cp_ByName_v2.Y = &retV
+			}
+			cp.ByName[k2] = cp_ByName_v2
+		}
+	}
+	return cp
+}`
+
+	CatalogCopyOnWrite = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Catalog
+func (o Catalog) DeepCopy() Catalog {
+	var cp Catalog = o
+	// cp.Items is shared with o.Items until written: copy-on-write
+	// cp.Index is shared with o.Index until written: copy-on-write
+	if o.Tags != nil {
+		cp.Tags = make([]string, len(o.Tags))
+		copy(cp.Tags, o.Tags)
+	}
+	return cp
+}`
+
+	ShapeUnion = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Shape
+func (o Shape) DeepCopy() Shape {
+	var cp Shape = o
+	if o.Circle != nil {
+		cp.Circle = new(Circle)
+		*cp.Circle = *o.Circle
+		if o.Circle.Tags != nil {
+			cp.Circle.Tags = make([]string, len(o.Circle.Tags))
+			copy(cp.Circle.Tags, o.Circle.Tags)
+		}
+	}
+	if o.Square != nil {
+		cp.Square = new(Square)
+		*cp.Square = *o.Square
+	}
+	return cp
+}`
+
+	ShapeUnionOneOf = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Shape
+func (o Shape) DeepCopy() Shape {
+	var cp Shape = o
+	{
+		set := 0
+		if o.Circle != nil {
+			set++
+		}
+		if o.Square != nil {
+			set++
+		}
+		if set != 1 {
+			panic("exactly one of Circle, Square of Shape must be set")
+		}
+	}
+	if o.Circle != nil {
+		cp.Circle = new(Circle)
+		*cp.Circle = *o.Circle
+		if o.Circle.Tags != nil {
+			cp.Circle.Tags = make([]string, len(o.Circle.Tags))
+			copy(cp.Circle.Tags, o.Circle.Tags)
+		}
+	}
+	if o.Square != nil {
+		cp.Square = new(Square)
+		*cp.Square = *o.Square
+	}
+	return cp
+}`
+
+	FooAllocCounter = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+import (
+	"sync/atomic"
+)
+
+// DeepCopyAllocs counts the allocations made by the generated DeepCopy methods.
+var DeepCopyAllocs atomic.Int64
+
+// DeepCopy generates a deep copy of Foo
+func (o Foo) DeepCopy() Foo {
+	var cp Foo = o
+	if o.Map != nil {
+		cp.Map = make(map[string]*Bar, len(o.Map))
+		DeepCopyAllocs.Add(1)
+		for k2, v2 := range o.Map {
+			var cp_Map_v2 *Bar = v2
+			if v2 != nil {
+				cp_Map_v2 = new(Bar)
+				*cp_Map_v2 = *v2
+				DeepCopyAllocs.Add(1)
+				if v2.Slice != nil {
+					cp_Map_v2.Slice = make([]string, len(v2.Slice))
+					DeepCopyAllocs.Add(1)
+					copy(cp_Map_v2.Slice, v2.Slice)
+				}
+			}
+			cp.Map[k2] = cp_Map_v2
+		}
+	}
+	if o.ch != nil {
+		cp.ch = make(chan float32, cap(o.ch))
+		DeepCopyAllocs.Add(1)
+	}
+	if o.baz.StringPointer != nil {
+		cp.baz.StringPointer = new(string)
+		*cp.baz.StringPointer = *o.baz.StringPointer
+		DeepCopyAllocs.Add(1)
+	}
+	return cp
+}`
+
+	AnyFieldsAny = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of AnyFields
+func (o AnyFields) DeepCopy() AnyFields {
+	var cp AnyFields = o
+	if o.List != nil {
+		cp.List = make([]any, len(o.List))
+		copy(cp.List, o.List)
+	}
+	if o.Props != nil {
+		cp.Props = make(map[string]any, len(o.Props))
+		for k2, v2 := range o.Props {
+			cp.Props[k2] = v2
+		}
+	}
+	if o.Legacy != nil {
+		cp.Legacy = make([]any, len(o.Legacy))
+		copy(cp.Legacy, o.Legacy)
+	}
+	return cp
+}`
+
+	AnyFieldsInterfaceLiteral = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of AnyFields
+func (o AnyFields) DeepCopy() AnyFields {
+	var cp AnyFields = o
+	if o.List != nil {
+		cp.List = make([]interface{}, len(o.List))
+		copy(cp.List, o.List)
+	}
+	if o.Props != nil {
+		cp.Props = make(map[string]interface{}, len(o.Props))
+		for k2, v2 := range o.Props {
+			cp.Props[k2] = v2
+		}
+	}
+	if o.Legacy != nil {
+		cp.Legacy = make([]interface{}, len(o.Legacy))
+		copy(cp.Legacy, o.Legacy)
+	}
+	return cp
+}`
+
+	LockHoldersReuse = `// Code generated by deep-copy; DO NOT EDIT.
+
+package locks
+
+// DeepCopy generates a deep copy of Service
+func (o Service) DeepCopy() Service {
+	var cp Service = o
+	if o.Counter != nil {
+		cp.Counter = o.Counter.DeepCopy()
+	}
+	if o.Cache != nil {
+		cp.Cache = o.Cache.Clone()
+	}
+	if o.Names != nil {
+		cp.Names = make([]string, len(o.Names))
+		copy(cp.Names, o.Names)
+	}
+	return cp
+}`
+
+	FooNilSafe = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of *Foo
+func (o *Foo) DeepCopy() *Foo {
+	if o == nil {
+		return nil
+	}
+	var cp Foo = *o
+	if o.Map != nil {
+		cp.Map = make(map[string]*Bar, len(o.Map))
+		for k2, v2 := range o.Map {
+			var cp_Map_v2 *Bar = v2
+			if v2 != nil {
+				cp_Map_v2 = new(Bar)
+				*cp_Map_v2 = *v2
+				if v2.Slice != nil {
+					cp_Map_v2.Slice = make([]string, len(v2.Slice))
+					copy(cp_Map_v2.Slice, v2.Slice)
+				}
+			}
+			cp.Map[k2] = cp_Map_v2
+		}
+	}
+	if o.ch != nil {
+		cp.ch = make(chan float32, cap(o.ch))
+	}
+	if o.baz.StringPointer != nil {
+		cp.baz.StringPointer = new(string)
+		*cp.baz.StringPointer = *o.baz.StringPointer
+	}
+	return &cp
+}`
+
+	InterfaceSliceSwitch = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Tree
+func (o Tree) DeepCopy() Tree {
+	var cp Tree = o
+	if o.Children != nil {
+		cp.Children = make([]Node, len(o.Children))
+		copy(cp.Children, o.Children)
+		for i2 := range o.Children {
+			if o.Children[i2] != nil {
+				switch t3 := o.Children[i2].(type) {
+				case Leaf:
+					cp.Children[i2] = t3.DeepCopy()
+				case *Leaf:
+					if t3 != nil {
+						retV := t3.DeepCopy()
+						cp.Children[i2] = &retV
+					}
+				case *Branch:
+					if t3 != nil {
+						retV := t3.DeepCopy()
+						cp.Children[i2] = &retV
+					}
+				}
+			}
+		}
+	}
+	return cp
+}
+
+// DeepCopy generates a deep copy of Leaf
+func (o Leaf) DeepCopy() Leaf {
+	var cp Leaf = o
+	if o.Tags != nil {
+		cp.Tags = make([]string, len(o.Tags))
+		copy(cp.Tags, o.Tags)
+	}
+	return cp
+}
+
+// DeepCopy generates a deep copy of Branch
+func (o Branch) DeepCopy() Branch {
+	var cp Branch = o
+	return cp
+}`
+
+	TagsByNameFile = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of TagsByName
+func (o TagsByName) DeepCopy() TagsByName {
+	var cp TagsByName = o
+	if o.M != nil {
+		cp.M = make(map[string]Tags, len(o.M))
+		for k2, v2 := range o.M {
+			var cp_M_v2 Tags = v2
+			cp_M_v2 = v2.DeepCopy()
+			cp.M[k2] = cp_M_v2
+		}
+	}
+	return cp
+}`
+
+	CopierInterface = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopier is implemented by the types with a generated DeepCopy method.
+type DeepCopier[T any] interface {
+	DeepCopy() T
+}
+
+var _ DeepCopier[*Bar] = (*Bar)(nil)
+var _ DeepCopier[*Baz] = (*Baz)(nil)
+
+// DeepCopy generates a deep copy of *Bar
+func (o *Bar) DeepCopy() *Bar {
+	var cp Bar = *o
+	if o.Slice != nil {
+		cp.Slice = make([]string, len(o.Slice))
+		copy(cp.Slice, o.Slice)
+	}
+	return &cp
+}
+
+// DeepCopy generates a deep copy of *Baz
+func (o *Baz) DeepCopy() *Baz {
+	var cp Baz = *o
+	if o.StringPointer != nil {
+		cp.StringPointer = new(string)
+		*cp.StringPointer = *o.StringPointer
+	}
+	return &cp
+}`
+
+	ConcreteRefsFile = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of ConcreteRefs
+func (o ConcreteRefs) DeepCopy() ConcreteRefs {
+	var cp ConcreteRefs = o
+	if o.First != nil {
+		retV := o.First.DeepCopy()
+		cp.First = &retV
+	}
+	if o.Second != nil {
+		retV := o.Second.DeepCopy()
+		cp.Second = &retV
+	}
+	if o.ByName != nil {
+		cp.ByName = make(map[string]*Concrete, len(o.ByName))
+		for k2, v2 := range o.ByName {
+			var cp_ByName_v2 *Concrete = v2
+			if v2 != nil {
+				retV := v2.DeepCopy()
+				cp_ByName_v2 = &retV
+			}
+			cp.ByName[k2] = cp_ByName_v2
+		}
+	}
+	return cp
+}`
+
+	SnapshotValueCopy = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Snapshot
+func (o Snapshot) DeepCopy() Snapshot {
+	var cp Snapshot = o
+	if o.Labels != nil {
+		cp.Labels = make([]string, len(o.Labels))
+		copy(cp.Labels, o.Labels)
+	}
+	if o.Origin != nil {
+		cp.Origin = new(Point)
+		*cp.Origin = *o.Origin
+	}
+	if o.Bounds.Slice != nil {
+		cp.Bounds.Slice = make([]string, len(o.Bounds.Slice))
+		copy(cp.Bounds.Slice, o.Bounds.Slice)
+	}
+	return cp
+}`
+
+	SnapshotExplicitFields = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Snapshot
+func (o Snapshot) DeepCopy() Snapshot {
+	var cp Snapshot
+	cp.ID = o.ID
+	cp.Samples = o.Samples
+	if o.Labels != nil {
+		cp.Labels = make([]string, len(o.Labels))
+		copy(cp.Labels, o.Labels)
+	}
+	if o.Origin != nil {
+		cp.Origin = new(Point)
+		*cp.Origin = *o.Origin
+	}
+	cp.Bounds = o.Bounds
+	if o.Bounds.Slice != nil {
+		cp.Bounds.Slice = make([]string, len(o.Bounds.Slice))
+		copy(cp.Bounds.Slice, o.Bounds.Slice)
+	}
+	return cp
+}`
+
+	FooExplicitFieldsPointer = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of *Foo
+func (o *Foo) DeepCopy() *Foo {
+	var cp Foo
+	if o.Map != nil {
+		cp.Map = make(map[string]*Bar, len(o.Map))
+		for k2, v2 := range o.Map {
+			var cp_Map_v2 *Bar = v2
+			if v2 != nil {
+				cp_Map_v2 = new(Bar)
+				*cp_Map_v2 = *v2
+				if v2.Slice != nil {
+					cp_Map_v2.Slice = make([]string, len(v2.Slice))
+					copy(cp_Map_v2.Slice, v2.Slice)
+				}
+			}
+			cp.Map[k2] = cp_Map_v2
+		}
+	}
+	if o.ch != nil {
+		cp.ch = make(chan float32, cap(o.ch))
+	}
+	cp.baz = o.baz
+	if o.baz.StringPointer != nil {
+		cp.baz.StringPointer = new(string)
+		*cp.baz.StringPointer = *o.baz.StringPointer
+	}
+	return &cp
+}`
+
+	WorkerRecreateChannels = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Worker
+func (o Worker) DeepCopy() Worker {
+	var cp Worker = o
+	if o.done != nil {
+		cp.done = make(chan struct{}, cap(o.done))
+	}
+	if o.Jobs != nil {
+		cp.Jobs = make(chan int, cap(o.Jobs))
+	}
+	return cp
+}`
+
+	WorkerShareSignals = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Worker
+func (o Worker) DeepCopy() Worker {
+	var cp Worker = o
+	if o.Jobs != nil {
+		cp.Jobs = make(chan int, cap(o.Jobs))
+	}
+	return cp
+}`
+
+	WorkerSkipSignal = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Worker
+//
+// skips: done
+func (o Worker) DeepCopy() Worker {
+	var cp Worker = o
+	if o.Jobs != nil {
+		cp.Jobs = make(chan int, cap(o.Jobs))
+	}
+	return cp
+}`
+
+	GammaBuildConstraint = `//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Gamma
+func (o Gamma) DeepCopy() Gamma {
+	var cp Gamma = o
+	return cp
+}`
+
+	GammaBuildConstraintTags = `//go:build (!ignore_autogenerated || debug) && anotherOne
+// +build !ignore_autogenerated debug
+// +build anotherOne
+
+// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of Gamma
+func (o Gamma) DeepCopy() Gamma {
+	var cp Gamma = o
+	return cp
+}`
+
+	Issue17MaxDepthShallow = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of *Depth1
+func (o *Depth1) DeepCopy() *Depth1 {
+	var cp Depth1 = *o
+	if o.a1 != nil {
+		cp.a1 = new(Depth2)
+		*cp.a1 = *o.a1
+		// cp.a1: shallow copy below max depth
+	}
+	if o.a2 != nil {
+		cp.a2 = new(Depth2)
+		*cp.a2 = *o.a2
+		// cp.a2: shallow copy below max depth
+	}
+	return &cp
+}`
+
+	TaggedPolicySkip = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of TaggedPolicy
+func (o TaggedPolicy) DeepCopy() TaggedPolicy {
+	var cp TaggedPolicy = o
+	if o.Data != nil {
+		cp.Data = make([]byte, len(o.Data))
+		copy(cp.Data, o.Data)
+	}
+	return cp
+}`
+
+	MapKeysShared = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of MapKeys
+func (o MapKeys) DeepCopy() MapKeys {
+	var cp MapKeys = o
+	if o.ByPointer != nil {
+		cp.ByPointer = make(map[*ValueKey]int, len(o.ByPointer))
+		for k2, v2 := range o.ByPointer {
+			cp.ByPointer[k2] = v2
+		}
+	}
+	if o.ByStruct != nil {
+		cp.ByStruct = make(map[StructKey][]int, len(o.ByStruct))
+		for k2, v2 := range o.ByStruct {
+			var cp_ByStruct_v2 []int = v2
+			if v2 != nil {
+				cp_ByStruct_v2 = make([]int, len(v2))
+				copy(cp_ByStruct_v2, v2)
+			}
+			cp.ByStruct[k2] = cp_ByStruct_v2
+		}
+	}
+	return cp
+}`
+
+	MapKeysCopied = `// Code generated by deep-copy; DO NOT EDIT.
+
+package testdata
+
+// DeepCopy generates a deep copy of MapKeys
+func (o MapKeys) DeepCopy() MapKeys {
+	var cp MapKeys = o
+	if o.ByPointer != nil {
+		cp.ByPointer = make(map[*ValueKey]int, len(o.ByPointer))
+		for k2, v2 := range o.ByPointer {
+			cp.ByPointer[k2] = v2
+		}
+	}
+	if o.ByStruct != nil {
+		cp.ByStruct = make(map[StructKey][]int, len(o.ByStruct))
+		for k2, v2 := range o.ByStruct {
+			var cp_ByStruct_k2 StructKey = k2
+			if k2.Ref != nil {
+				cp_ByStruct_k2.Ref = new(int)
+				*cp_ByStruct_k2.Ref = *k2.Ref
+			}
+			var cp_ByStruct_v2 []int = v2
+			if v2 != nil {
+				cp_ByStruct_v2 = make([]int, len(v2))
+				copy(cp_ByStruct_v2, v2)
+			}
+			cp.ByStruct[cp_ByStruct_k2] = cp_ByStruct_v2
+		}
+	}
+	return cp
+}`
+
+	MutualCyclesTest = `package mutual
+
+import "testing"
+
+func TestCycles(t *testing.T) {
+	a := &A{Name: "a"}
+	a.B = &B{Tags: []string{"t"}, A: a}
+
+	cp := a.DeepCopy()
+	if cp.B == a.B || cp.B.A == a {
+		t.Fatal("the copy shares pointers with the source")
+	}
+	if cp.B.A.B != cp.B {
+		t.Fatal("the copy of A -> B -> A is not a cycle")
+	}
+	if cp.B.Tags[0] = "u"; a.B.Tags[0] != "t" {
+		t.Fatal("the copy shares the tags with the source")
+	}
+
+	team := &Team{}
+	team.Lead = Member{Name: "lead", Team: team}
+	team.Members = []*Member{{Name: "m", Team: team}}
+
+	cp2 := team.DeepCopy()
+	if cp2.Lead.Team == team || cp2.Members[0] == team.Members[0] {
+		t.Fatal("the copy shares pointers with the source")
+	}
+	if cp2.Lead.Team != cp2.Members[0].Team {
+		t.Fatal("the copy of the team is not shared by its members")
+	}
+}
+`
+
+	ReuseMapsTest = `package reusemaps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReuseMaps(t *testing.T) {
+	src := &Frame{
+		Header: map[string][]string{"a": {"1"}},
+		Meta:   Meta{Annotations: map[string]string{"b": "2"}},
+	}
+	dst := &Frame{
+		Header: map[string][]string{"stale": {"0"}},
+		Meta:   Meta{Annotations: map[string]string{"stale": "0"}},
+	}
+	header, annotations := dst.Header, dst.Meta.Annotations
+
+	src.DeepCopyInto(dst)
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("DeepCopyInto() = %v, want %v", dst, src)
+	}
+	if header["stale"] != nil || annotations["stale"] != "" {
+		t.Error("stale entries are kept")
+	}
+	if dst.Header["a"][0] = "x"; src.Header["a"][0] != "1" {
+		t.Error("the copy shares its header values with the source")
+	}
+
+	labels, prev := Labels{"c": "3"}, Labels{"stale": "0"}
+	dstLabels := prev
+	labels.DeepCopyInto(&dstLabels)
+	if !reflect.DeepEqual(dstLabels, labels) || prev["stale"] != "" {
+		t.Errorf("DeepCopyInto() = %v, want %v", dstLabels, labels)
+	}
+}
+
+var benchFrame = &Frame{
+	Header: map[string][]string{"a": {"1"}, "b": {"2"}, "c": {"3"}},
+	Meta:   Meta{Annotations: map[string]string{"d": "4", "e": "5"}},
+}
+
+func BenchmarkReuseMaps(b *testing.B) {
+	dst := new(Frame)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchFrame.DeepCopyInto(dst)
+	}
+}
+
+func BenchmarkAllocateMaps(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchFrame.DeepCopyInto(new(Frame))
+	}
+}
+`
 )
@@ -0,0 +1,9 @@
+package testdata
+
+type AnonymousSliceElem struct {
+	Rows []struct{ X []int }
+}
+
+type AnonymousMapValue struct {
+	ByName map[string]struct{ Y *Foo }
+}
@@ -0,0 +1,7 @@
+package testdata
+
+type AnyFields struct {
+	List   []any
+	Props  map[string]any
+	Legacy []interface{}
+}
@@ -0,0 +1,10 @@
+package testdata
+
+type StringOrBytes interface {
+	~string | ~[]byte
+}
+
+type Text[T StringOrBytes] struct {
+	Value T
+	Lines []string
+}
@@ -0,0 +1,15 @@
+package testdata
+
+type Owner struct {
+	Pet  *Pet
+	Pets []Pet
+}
+
+type Pet struct {
+	Toys []string
+	Vet  *Vet
+}
+
+type Vet struct {
+	Patients map[string]int
+}
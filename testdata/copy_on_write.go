@@ -0,0 +1,8 @@
+package testdata
+
+type Catalog struct {
+	Name  string
+	Items []string
+	Index map[string]int
+	Tags  []string
+}
@@ -0,0 +1,20 @@
+package testdata
+
+type Stringer interface {
+	String() string
+}
+
+type EmbedsInterface struct {
+	Stringer
+	X      int
+	Values []int
+}
+
+type Copier interface {
+	DeepCopy() Copier
+}
+
+type EmbedsCopier struct {
+	Copier
+	X int
+}
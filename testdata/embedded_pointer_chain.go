@@ -0,0 +1,14 @@
+package testdata
+
+type EmbedsPointer struct {
+	*EmbeddedData
+}
+
+type EmbeddedData struct {
+	Data []int
+}
+
+type EmbedsPointerChain struct {
+	*EmbedsPointer
+	Name string
+}
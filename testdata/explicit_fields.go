@@ -0,0 +1,13 @@
+package testdata
+
+type Point struct {
+	X, Y int
+}
+
+type Snapshot struct {
+	ID      int
+	Samples [256]float64
+	Labels  []string
+	Origin  *Point
+	Bounds  Bar
+}
@@ -0,0 +1,29 @@
+package aliases
+
+type Config struct {
+	Tags    []string
+	Limits  LimitsAlias
+	Servers []ServerAlias
+}
+
+type ConfigAlias = Config
+
+type Limits struct {
+	Max []int
+}
+
+func (l Limits) DeepCopy() Limits {
+	cp := l
+	cp.Max = append([]int(nil), l.Max...)
+	return cp
+}
+
+type LimitsAlias = Limits
+
+type Server struct {
+	Ports []int
+}
+
+type ServerAlias = Server
+
+type Servers = []Server
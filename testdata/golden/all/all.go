@@ -0,0 +1,33 @@
+package all
+
+type Item struct {
+	Tags []string
+}
+
+type Items []Item
+
+type Index map[string]*Item
+
+type Manual struct {
+	Data []byte
+}
+
+func (m Manual) DeepCopy() Manual {
+	return Manual{Data: append([]byte(nil), m.Data...)}
+}
+
+type Excluded struct {
+	Data []byte
+}
+
+type ItemAlias = Item
+
+type Count int
+
+type Getter interface {
+	Get() Item
+}
+
+type internal struct {
+	Data []byte
+}
@@ -0,0 +1,19 @@
+package allocators
+
+import "sync"
+
+var bufferPool = sync.Pool{New: func() any { return new(Buffer) }}
+
+func getBuffer() *Buffer {
+	return bufferPool.Get().(*Buffer)
+}
+
+type Buffer struct {
+	Data []byte
+}
+
+type Request struct {
+	Body    *Buffer
+	Parts   []*Buffer
+	Trailer *string
+}
@@ -0,0 +1,13 @@
+package arrays
+
+type Node struct {
+	Values []int
+}
+
+type Ring struct {
+	Nodes   [16]*Node
+	Lines   [4][]byte
+	Scalars [8]int64
+	Grid    [2][3]*int
+	Plain   [2]Node
+}
@@ -0,0 +1,18 @@
+package assertions
+
+import "time"
+
+type Item struct {
+	Name string
+	Tags []string
+}
+
+type Order struct {
+	Items    []*Item
+	Index    map[string]*Item
+	Grid     [2][]int
+	Cache    []byte
+	Zone     *time.Location
+	notes    []string
+	OnChange func()
+}
@@ -0,0 +1,8 @@
+package chanbufs
+
+type Queue struct {
+	Jobs    chan int
+	Batches []chan []string
+	Results <-chan int
+	Done    chan struct{}
+}
@@ -0,0 +1,7 @@
+package chans
+
+type Chans struct {
+	Events  chan string
+	Done    chan struct{}
+	Results <-chan int
+}
@@ -0,0 +1,9 @@
+package comments
+
+type Account struct {
+	Roles []string
+}
+
+type Team struct {
+	Members []*Account
+}
@@ -0,0 +1,12 @@
+package crosspkg
+
+import "github.com/globusdigital/deep-copy/testdata/golden/crosspkg/ext"
+
+type Inner struct {
+	secret []int
+}
+
+type Holder struct {
+	Wrapper ext.Wrapper
+	Box     ext.Box[Inner]
+}
@@ -0,0 +1,14 @@
+package ext
+
+type Wrapper struct {
+	Inner struct {
+		secret []int
+		Public []int
+	}
+	hidden []int
+}
+
+type Box[T any] struct {
+	V    *T
+	tags []string
+}
@@ -0,0 +1,12 @@
+package cycles
+
+type Node struct {
+	Value    int
+	Next     *Node
+	Children []*Node
+	Tags     []string
+}
+
+type Ring struct {
+	Head *Node
+}
@@ -0,0 +1,15 @@
+package each
+
+import (
+	"net/url"
+	"time"
+)
+
+type Page struct {
+	Links    []*url.URL
+	Schedule Schedule
+}
+
+type Schedule struct {
+	Times []time.Time
+}
@@ -0,0 +1,14 @@
+package elements
+
+type Inner struct {
+	Data []byte
+	Meta map[string][]byte
+}
+
+type Elements struct {
+	Items   []Inner
+	Grid    [][]Inner
+	ByName  map[string][]Inner
+	Pair    [2][]Inner
+	Indexed []map[string]Inner
+}
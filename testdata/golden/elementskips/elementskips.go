@@ -0,0 +1,13 @@
+package elementskips
+
+type Item struct {
+	Secret *string
+	Tags   []string
+}
+
+type Order struct {
+	Items  []Item
+	ByName map[string][]Item
+	Grid   [][]Item
+	Notes  map[string]*string
+}
@@ -0,0 +1,35 @@
+package embedded
+
+type Base struct {
+	ID       int
+	Tags     []string
+	Labels   map[string]string
+	Shadowed []int
+}
+
+type Meta struct {
+	Notes []string
+}
+
+type Item struct {
+	Base
+	Data []byte
+}
+
+type Doc struct {
+	Base
+	*Meta
+	Shadowed []int
+	Items    []Item
+}
+
+// Core has unexported fields, copied through the embedded pointer of Outer.
+type Core struct {
+	id   int
+	refs []string
+}
+
+type Outer struct {
+	*Core
+	Name string
+}
@@ -0,0 +1,11 @@
+package errors
+
+// fmt is named like the package imported by the copies wrapping errors.
+type fmt struct{}
+
+type Result struct {
+	Value string
+	Err   error
+	Errs  []error
+	ByKey map[string]error
+}
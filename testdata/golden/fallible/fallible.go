@@ -0,0 +1,30 @@
+package fallible
+
+import "encoding/json"
+
+type Payload struct {
+	Fields map[string]any
+}
+
+func (p Payload) DeepCopyE() (Payload, error) {
+	var cp Payload
+	b, err := json.Marshal(p)
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(b, &cp)
+	return cp, err
+}
+
+type Part struct {
+	Name string
+	Body *Payload
+}
+
+type Message struct {
+	Head    Payload
+	Parts   []Part
+	ByName  map[string]*Payload
+	Labels  []string
+	Primary *Part
+}
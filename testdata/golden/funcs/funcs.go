@@ -0,0 +1,15 @@
+package funcs
+
+type Handler struct {
+	Name     string
+	Validate func(int) error
+}
+
+type Hooks struct {
+	OnChange func(int) error
+	Handlers []Handler
+	ByName   map[string]func(int) error
+	Tags     []string
+	Skipped  func()
+	Default  Handler
+}
@@ -0,0 +1,10 @@
+package genericifaces
+
+type Holder struct {
+	V  any
+	Vs []any
+}
+
+type Box[T any] struct {
+	Vs []T
+}
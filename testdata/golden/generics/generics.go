@@ -0,0 +1,41 @@
+package generics
+
+type Cloner[T any] interface {
+	DeepCopy() T
+}
+
+type Inner[T any] struct {
+	Items []T
+}
+
+type Box[T any] struct {
+	V     T
+	Vs    []T
+	ByKey map[string]T
+	P     *T
+	In    Inner[T]
+	InP   *Inner[T]
+	Ints  Inner[int]
+}
+
+type Doc struct {
+	Tags []string
+}
+
+func (d Doc) DeepCopy() Doc {
+	return Doc{Tags: append([]string(nil), d.Tags...)}
+}
+
+type Library struct {
+	Docs Store[Doc]
+}
+
+type Store[T Cloner[T]] struct {
+	Cur  T
+	All  []T
+	Last *T
+}
+
+type Pair[K comparable, V any] struct {
+	M map[K][]V
+}
@@ -0,0 +1,29 @@
+package helpers
+
+type Level4 struct {
+	Data []int
+}
+
+type Level3 struct {
+	ByName map[string]Level4
+	Last   *Level4
+}
+
+type Level2 struct {
+	Items []Level3
+}
+
+type Level1 struct {
+	Left  Level2
+	Right *Level2
+}
+
+type Tree struct {
+	Value    int
+	Children []*Tree
+}
+
+type Forest struct {
+	Root Level1
+	Tree Tree
+}
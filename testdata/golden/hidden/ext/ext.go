@@ -0,0 +1,30 @@
+package ext
+
+type Copyable struct {
+	items []int
+	Name  string
+	Tags  []string
+}
+
+func (c Copyable) DeepCopy() Copyable {
+	c.items = append([]int(nil), c.items...)
+	c.Tags = append([]string(nil), c.Tags...)
+	return c
+}
+
+type Cloneable struct {
+	items []int
+	Tags  []string
+}
+
+func (c *Cloneable) Clone() *Cloneable {
+	return &Cloneable{
+		items: append([]int(nil), c.items...),
+		Tags:  append([]string(nil), c.Tags...),
+	}
+}
+
+type Opaque struct {
+	items []int
+	Tags  []string
+}
@@ -0,0 +1,12 @@
+package hidden
+
+import "github.com/globusdigital/deep-copy/testdata/golden/hidden/ext"
+
+type Holder struct {
+	C  ext.Copyable
+	CP *ext.Copyable
+	Cs []ext.Copyable
+	K  ext.Cloneable
+	KP *ext.Cloneable
+	O  ext.Opaque
+}
@@ -0,0 +1,29 @@
+package ifacehelpers
+
+type Shape interface {
+	DeepCopy() Shape
+	Area() float64
+}
+
+type Payload interface {
+	DeepCopy() any
+}
+
+type Circle struct {
+	Radius float64
+}
+
+func (c Circle) DeepCopy() Shape { return c }
+func (c Circle) Area() float64   { return 3.14 * c.Radius * c.Radius }
+
+type Drawing struct {
+	Main    Shape
+	Shapes  []Shape
+	ByName  map[string]Shape
+	Payload Payload
+	Inline  interface{ DeepCopy() any }
+}
+
+type Layer struct {
+	Shapes [4]Shape
+}
@@ -0,0 +1,11 @@
+package importas
+
+import (
+	"bytes"
+	"net/url"
+)
+
+type Request struct {
+	URL  *url.URL
+	Body *bytes.Buffer
+}
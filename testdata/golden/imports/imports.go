@@ -0,0 +1,15 @@
+package imports
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"time"
+)
+
+type Imports struct {
+	URLs    []url.URL
+	Buffers []*bytes.Buffer
+	Modes   map[string]os.FileMode
+	Times   []time.Time
+}
@@ -0,0 +1,21 @@
+package includes
+
+type Address struct {
+	Lines []string
+	Geo   *float64
+}
+
+type Line struct {
+	SKU    string
+	Tags   []string
+	Prices map[string]float64
+}
+
+type Order struct {
+	ID       string
+	Address  Address
+	Lines    []Line
+	Notes    []string
+	Meta     map[string]string
+	Contacts []*Address
+}
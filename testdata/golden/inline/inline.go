@@ -0,0 +1,36 @@
+package inline
+
+import "time"
+
+type Doc struct {
+	Meta struct {
+		Tags  []string
+		Attrs map[string][]int
+		Inner struct {
+			Ptr *int
+		}
+	}
+	Ptr  *struct{ Labels []string }
+	Rows []struct {
+		Cells []string `json:"cells"`
+		At    *time.Time
+	}
+	ByKey map[string]*struct{ IDs []int64 }
+	Arr   [2]struct{ B []byte }
+	Nest  []map[string]struct{ V []int }
+}
+
+type Item struct {
+	Name string
+}
+
+type Export struct {
+	Group struct {
+		Items []Item
+		Refs  map[string]*Item
+	}
+	List []struct {
+		Item  *Item
+		Items []Item
+	}
+}
@@ -0,0 +1,17 @@
+package interfaces
+
+type Payload interface {
+	DeepCopy() interface{}
+}
+
+type Message interface {
+	DeepCopy() Message
+	Topic() string
+}
+
+type Event struct {
+	Payload Payload
+	Message Message
+	Meta    interface{}
+	Extra   []any
+}
@@ -0,0 +1,14 @@
+package into
+
+type Frame struct {
+	Header  map[string]string
+	Payload []byte
+	Samples Samples
+}
+
+type Samples []float64
+
+type Span struct {
+	Name   string
+	Parent *Span
+}
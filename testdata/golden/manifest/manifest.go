@@ -0,0 +1,18 @@
+package manifest
+
+import "time"
+
+type Contact struct {
+	Email  string
+	Phones []string
+}
+
+type Customer struct {
+	ID       int
+	Contacts []Contact
+	Primary  *Contact
+	Created  time.Time
+	notes    map[string]string
+}
+
+type IDs []int
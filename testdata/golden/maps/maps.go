@@ -0,0 +1,26 @@
+package maps
+
+import "encoding/json"
+
+type Entry struct {
+	Refs []string
+}
+
+type Node struct {
+	Entry *Entry
+	Tags  []string
+}
+
+type Maps struct {
+	Counts  map[string]int
+	Entries map[string]Entry
+	Ptrs    map[int]*Entry
+	Lists   map[string][]int
+	ByName  map[string]*Entry
+	Nodes   map[string]*Node
+	Grids   map[string][][]int
+	Refs    map[string][]*Entry
+	Nested  map[string]map[string][]int
+	Blobs   map[string][]byte
+	Raw     map[string]json.RawMessage
+}
@@ -0,0 +1,19 @@
+package methods
+
+type Ledger struct {
+	Accounts []Account
+	Primary  *Account
+	Notes    []string
+}
+
+type Account struct {
+	Entries []int
+}
+
+// DeepCopy copies the account, sharing its entries if shallow.
+func (a Account) DeepCopy(shallow bool) Account {
+	if shallow {
+		return a
+	}
+	return a.Clone()
+}
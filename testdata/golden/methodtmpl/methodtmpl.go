@@ -0,0 +1,10 @@
+package methodtmpl
+
+type Inner struct {
+	Tags []string
+}
+
+type Outer struct {
+	Inner  *Inner
+	Others []Inner
+}
@@ -0,0 +1,21 @@
+package mutual
+
+type A struct {
+	Name string
+	B    *B
+}
+
+type B struct {
+	Tags []string
+	A    *A
+}
+
+type Team struct {
+	Lead    Member
+	Members []*Member
+}
+
+type Member struct {
+	Name string
+	Team *Team
+}
@@ -0,0 +1,23 @@
+package named
+
+import "maps"
+
+type IDs []int64
+
+func (ids IDs) Clone() IDs {
+	return append(IDs(nil), ids...)
+}
+
+type Index map[string]int
+
+func (i Index) Clone() Index {
+	return maps.Clone(i)
+}
+
+type Batch struct {
+	IDs    IDs
+	Index  Index
+	Groups []IDs
+	ByName map[string]IDs
+	Latest *IDs
+}
@@ -0,0 +1,34 @@
+package namedptrs
+
+type Node struct {
+	Tags []string
+}
+
+type (
+	Buf     *[]byte
+	NodeRef *Node
+	Count   *int
+)
+
+type Holder struct {
+	B    Buf
+	N    NodeRef
+	C    Count
+	Bufs []Buf
+	ByID map[string]NodeRef
+}
+
+type Leaf struct {
+	Data []byte
+}
+
+func (l Leaf) DeepCopy() Leaf {
+	return Leaf{Data: append([]byte(nil), l.Data...)}
+}
+
+type LeafRef *Leaf
+
+type Tree struct {
+	L  LeafRef
+	Ls []LeafRef
+}
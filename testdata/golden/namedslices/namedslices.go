@@ -0,0 +1,16 @@
+package namedslices
+
+import "encoding/json"
+
+type Bytes []byte
+
+type Chunk = []byte
+
+type Event struct {
+	Payload json.RawMessage
+	Batch   []json.RawMessage
+	ByKey   map[string]json.RawMessage
+	Pinned  *json.RawMessage
+	Own     Bytes
+	Chunk   Chunk
+}
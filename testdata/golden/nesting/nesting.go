@@ -0,0 +1,20 @@
+package nesting
+
+type Item struct {
+	Tags []string
+}
+
+// i3 is named like the index of the innermost loop copying Nesting.Shadowed.
+type i3 struct {
+	Tags []string
+}
+
+type Nesting struct {
+	Grid     [][]int
+	Items    [][]*Item
+	Maps     []map[string][]int
+	Arrays   [2][3]*Item
+	Cube     [][][]*Item
+	Entries  map[string][]map[string]*Item
+	Shadowed [][]*i3
+}
@@ -0,0 +1,12 @@
+package nilpointers
+
+type Address struct {
+	Street *string
+	Lines  []string
+}
+
+type Contact struct {
+	Age     *int
+	Address *Address
+	Phones  []*string
+}
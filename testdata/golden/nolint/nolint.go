@@ -0,0 +1,15 @@
+package nolint
+
+type Node struct {
+	Value    []int
+	Children []*Node
+}
+
+type Section struct {
+	Lines []string
+}
+
+type Report struct {
+	Root     *Node
+	Sections []Section
+}
@@ -0,0 +1,20 @@
+package pkgname
+
+import "time"
+
+type Account struct {
+	ID      int
+	Owner   *User
+	Roles   []string
+	Created time.Time
+	secrets []string
+}
+
+type User struct {
+	Name    string
+	Friends []*User
+}
+
+type Page[T any] struct {
+	Items []T
+}
@@ -0,0 +1,16 @@
+package pointers
+
+type Node struct {
+	Value int
+	Tags  []string
+}
+
+type Pointers struct {
+	Int    *int
+	Node   *Node
+	Nodes  []*Node
+	PtrPtr **int
+	Slice  *[]string
+	Map    *map[string]int
+	Empty  []string
+}
@@ -0,0 +1,11 @@
+package ptrptrs
+
+type Leaf struct {
+	Tags []string
+}
+
+type Holder struct {
+	Count **int
+	Leaf  **Leaf
+	Deep  ***Leaf
+}
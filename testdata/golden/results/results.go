@@ -0,0 +1,20 @@
+package results
+
+type Address struct {
+	Lines []string
+}
+
+type Customer struct {
+	Name    string
+	Tags    []string
+	Address *Address
+}
+
+// CustomerCopy is a distinct type with the fields of Customer, the copies
+// of which are returned as CustomerCopy.
+type CustomerCopy Customer
+
+type Order struct {
+	Customer Customer
+	Items    map[string]int
+}
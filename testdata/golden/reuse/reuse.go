@@ -0,0 +1,32 @@
+package reuse
+
+type Value struct {
+	Data []byte
+}
+
+func (v Value) DeepCopy() Value {
+	cp := v
+	cp.Data = append([]byte(nil), v.Data...)
+	return cp
+}
+
+type Pointer struct {
+	Data []byte
+}
+
+func (p *Pointer) DeepCopy() *Pointer {
+	cp := *p
+	cp.Data = append([]byte(nil), p.Data...)
+	return &cp
+}
+
+type Reuse struct {
+	Value      Value
+	ValuePtr   *Value
+	Pointer    *Pointer
+	PointerVal Pointer
+	Values     []Value
+	ByName     map[string]*Pointer
+	Pointers   []Pointer
+	Array      [2]Value
+}
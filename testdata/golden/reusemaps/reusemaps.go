@@ -0,0 +1,14 @@
+package reusemaps
+
+type Labels map[string]string
+
+type Meta struct {
+	Annotations map[string]string
+}
+
+type Frame struct {
+	Header  map[string][]string
+	Meta    Meta
+	Parent  *Meta
+	Windows []map[string]int
+}
@@ -0,0 +1,27 @@
+package scopes
+
+type Config struct {
+	Hosts []string
+}
+
+func shadowed() {
+	// Config shadows the package-level type, which is still the one copied.
+	type Config struct {
+		Ports []int
+	}
+	_ = Config{}
+}
+
+func first() {
+	type Temp struct {
+		Values []int
+	}
+	_ = Temp{}
+}
+
+func second() {
+	type Temp struct {
+		Names []string
+	}
+	_ = Temp{}
+}
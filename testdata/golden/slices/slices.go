@@ -0,0 +1,13 @@
+package slices
+
+type Item struct {
+	Values []int
+}
+
+type Slices struct {
+	Ints    []int
+	Items   []Item
+	Ptrs    []*Item
+	Nested  [][]string
+	Scalars [4]int
+}
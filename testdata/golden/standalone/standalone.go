@@ -0,0 +1,23 @@
+package standalone
+
+type Order struct {
+	ID       int
+	Customer *Customer
+	Items    []Item
+	Notes    map[string]*Customer
+}
+
+type Customer struct {
+	Name   string
+	Emails []string
+}
+
+type Item struct {
+	SKU  string
+	Tags []string
+}
+
+type Tree struct {
+	Value    int
+	Children []*Tree
+}
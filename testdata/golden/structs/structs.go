@@ -0,0 +1,14 @@
+package structs
+
+type Inner struct {
+	Count int
+	Names []string
+}
+
+type Outer struct {
+	ID    int
+	Inner Inner
+	Meta  struct {
+		Tags []string
+	}
+}
@@ -0,0 +1,24 @@
+package syncs
+
+import "sync"
+
+type Store struct {
+	mu      sync.RWMutex
+	wg      sync.WaitGroup
+	once    sync.Once
+	cache   sync.Map
+	Entries map[string]string
+	Stats   Stats
+	Shards  []Shard
+	Primary *Shard
+}
+
+type Stats struct {
+	sync.Mutex
+	Hits []int
+}
+
+type Shard struct {
+	mu   sync.Mutex
+	Keys []string
+}
@@ -0,0 +1,15 @@
+package values
+
+import "time"
+
+type Event struct {
+	At       time.Time
+	Timeout  time.Duration
+	History  []time.Time
+	Deadline *time.Time
+	Window   Window
+}
+
+type Window struct {
+	Start, End time.Time
+}
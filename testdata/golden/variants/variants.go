@@ -0,0 +1,10 @@
+package variants
+
+type Config struct {
+	Name  string
+	Hosts []string
+}
+
+type Pair[T any] struct {
+	Values []T
+}
@@ -0,0 +1,21 @@
+package testdata
+
+type Node interface {
+	Name() string
+}
+
+type Leaf struct {
+	Tags []string
+}
+
+func (Leaf) Name() string { return "leaf" }
+
+type Branch struct {
+	Label string
+}
+
+func (*Branch) Name() string { return "branch" }
+
+type Tree struct {
+	Children []Node
+}
@@ -0,0 +1,50 @@
+package locks
+
+import "sync"
+
+// Counter guards its values, and copies them under its lock.
+type Counter struct {
+	mu     sync.Mutex
+	Values []int
+}
+
+func (c *Counter) DeepCopy() *Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp := &Counter{Values: make([]int, len(c.Values))}
+	copy(cp.Values, c.Values)
+	return cp
+}
+
+// Cache guards its entries, and clones them under its lock.
+type Cache struct {
+	mu      sync.RWMutex
+	Entries map[string]string
+}
+
+func (c *Cache) Clone() *Cache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cp := &Cache{Entries: make(map[string]string, len(c.Entries))}
+	for k, v := range c.Entries {
+		cp.Entries[k] = v
+	}
+	return cp
+}
+
+type Unguarded struct {
+	mu    sync.Mutex
+	Items []string
+}
+
+type Service struct {
+	Counter *Counter
+	Cache   *Cache
+	Names   []string
+}
+
+type Registry struct {
+	Plain Unguarded
+}
@@ -0,0 +1,9 @@
+package testdata
+
+type DynamicConfig struct {
+	Values map[any]any
+}
+
+type ConfigEntry struct {
+	Tags []string
+}
@@ -0,0 +1,55 @@
+package testdata
+
+type ValueKey struct {
+	ID   int
+	Name string
+}
+
+func (k ValueKey) DeepCopy() ValueKey {
+	return k
+}
+
+type PointerKey struct {
+	ID *int
+}
+
+func (k PointerKey) DeepCopy() PointerKey {
+	cp := k
+	if k.ID != nil {
+		cp.ID = new(int)
+		*cp.ID = *k.ID
+	}
+	return cp
+}
+
+type MapWithValueKey struct {
+	M map[ValueKey]string
+}
+
+type MapWithPointerKey struct {
+	M map[PointerKey]string
+}
+
+type VersionedKey struct {
+	Name    string
+	Version int
+}
+
+// DeepCopy drops the version, so keys differing only by it collapse.
+func (k VersionedKey) DeepCopy() VersionedKey {
+	return VersionedKey{Name: k.Name}
+}
+
+type MapWithVersionedKey struct {
+	M map[VersionedKey]string
+}
+
+type StructKey struct {
+	Name string
+	Ref  *int
+}
+
+type MapKeys struct {
+	ByPointer map[*ValueKey]int
+	ByStruct  map[StructKey][]int
+}
@@ -0,0 +1,16 @@
+package testdata
+
+type Tags []string
+
+func (t Tags) DeepCopy() Tags {
+	if t == nil {
+		return nil
+	}
+	cp := make(Tags, len(t))
+	copy(cp, t)
+	return cp
+}
+
+type TagsByName struct {
+	M map[string]Tags
+}
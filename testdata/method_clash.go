@@ -0,0 +1,14 @@
+package testdata
+
+type FuncField struct {
+	Name     string
+	DeepCopy func() FuncField
+}
+
+type WrongDeepCopy struct {
+	Items []int
+}
+
+func (w WrongDeepCopy) DeepCopy(shallow bool) WrongDeepCopy {
+	return w
+}
@@ -0,0 +1,5 @@
+package package_doc
+
+type Data struct {
+	Values []int
+}
@@ -0,0 +1,2 @@
+// Package package_doc already has a doc comment.
+package package_doc
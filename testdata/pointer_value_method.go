@@ -0,0 +1,20 @@
+package testdata
+
+type Concrete struct {
+	Values []int
+}
+
+func (c Concrete) DeepCopy() Concrete {
+	cp := c
+	if c.Values != nil {
+		cp.Values = make([]int, len(c.Values))
+		copy(cp.Values, c.Values)
+	}
+	return cp
+}
+
+type ConcreteRefs struct {
+	First  *Concrete
+	Second *Concrete
+	ByName map[string]*Concrete
+}
@@ -0,0 +1,19 @@
+package testdata
+
+type Record struct {
+	ID      int
+	Name    string
+	Created Timestamp
+	Cache   map[string][]byte
+	Tags    []string
+	Parts   []RecordPart
+}
+
+type Timestamp struct {
+	Seconds int64
+}
+
+type RecordPart struct {
+	ID    string
+	Valid bool
+}
@@ -0,0 +1,28 @@
+package shadowing
+
+import (
+	it "github.com/globusdigital/deep-copy/testdata/import_alias/item"
+)
+
+type Error struct {
+	Message *string
+}
+
+type Reader struct {
+	Buf []byte
+}
+
+type Map map[string][]int
+
+type item struct {
+	Items []it.Item
+}
+
+type o struct {
+	Next *o
+	cp   *cp
+}
+
+type cp struct {
+	Values []int
+}
@@ -0,0 +1,8 @@
+package shadowing_builtin
+
+type len int
+
+type Data struct {
+	Values []int
+	Size   len
+}
@@ -0,0 +1,9 @@
+package shared_pointers
+
+import "time"
+
+type Schedule struct {
+	Loc       *time.Location
+	Locations []*time.Location
+	Start     *int
+}
@@ -0,0 +1,6 @@
+package testdata
+
+type Worker struct {
+	done chan struct{}
+	Jobs chan int
+}
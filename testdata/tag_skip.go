@@ -0,0 +1,17 @@
+package testdata
+
+type TaggedSecrets struct {
+	Public    []string
+	Hidden    []string `json:"-"`
+	Named     []string `json:"named"`
+	Secret    *string  `secret:"true"`
+	NotSecret *string  `secret:"false"`
+}
+
+type TaggedPolicy struct {
+	Cache map[string][]byte `deepcopy:"skip"`
+	Items []string          `json:"items" deepcopy:"skip"`
+	Data  []byte            `deepcopy:""`
+	Peers map[string]*int   `deepcopy:"shallow"`
+	Owner *string           `deepcopy:"shallow"`
+}
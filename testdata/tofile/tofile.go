@@ -0,0 +1,5 @@
+package tofile
+
+type Config struct {
+	Hosts []string
+}
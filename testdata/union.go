@@ -0,0 +1,16 @@
+package testdata
+
+type Circle struct {
+	Radius float64
+	Tags   []string
+}
+
+type Square struct {
+	Side float64
+}
+
+type Shape struct {
+	Circle *Circle
+	Square *Square
+	Label  string
+}
@@ -0,0 +1,13 @@
+package testdata
+
+type Holder struct {
+	A  Held
+	B  *Held
+	C  Held
+	D  *Held
+	Hs []Held
+}
+
+type Held struct {
+	Values []int
+}